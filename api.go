@@ -1,103 +1,177 @@
-package main
+package gohta
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
-	"os"
+	"strings"
 )
 
-// API handler
-func apiHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// maxAPIRequestSize limits the size of API request bodies.
+const maxAPIRequestSize = 64 << 20
 
-	// Handle POST requests to /api/log path specifically
-	if r.URL.Path == "/api/log" && r.Method == http.MethodPost {
-		handleLogRequest(w, r)
-		return
+// APIFunc handles a named API method. params holds the raw JSON request body and is nil
+// when the request has no body. The returned value is encoded as the JSON response.
+type APIFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// APIError is an error with an HTTP status. API methods return it to report client errors.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// HandleAPI registers a raw API method. Registering the same name twice replaces the
+// handler. Replacing a built-in method such as "fs.readFile" logs a warning, since
+// gohta.js relies on them.
+func (a *App) HandleAPI(name string, fn APIFunc) {
+	a.apiMutex.Lock()
+	defer a.apiMutex.Unlock()
+	if a.apiBuiltins[name] {
+		slog.Warn("⚠️  API method replaces the built-in method of the same name", "method", name)
 	}
+	a.apiMethods[name] = fn
+}
 
-	// Handle POST requests to /api/core/convertFileSrc path
-	if r.URL.Path == "/api/core/convertFileSrc" && r.Method == http.MethodPost {
-		handleConvertFileSrcRequest(w, r)
-		return
+// markBuiltinAPI records the methods registered so far as built-in.
+func (a *App) markBuiltinAPI() {
+	a.apiMutex.Lock()
+	defer a.apiMutex.Unlock()
+	a.apiBuiltins = make(map[string]bool, len(a.apiMethods))
+	for name := range a.apiMethods {
+		a.apiBuiltins[name] = true
 	}
+}
+
+// Register registers an API method of app with typed request and response values.
+// The method "notes.read" is served at /api/notes/read and called from
+// JavaScript with gohta.invoke("notes.read", args).
+func Register[Req, Resp any](a *App, name string, fn func(context.Context, Req) (Resp, error)) {
+	a.HandleAPI(name, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req Req
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &req); err != nil {
+				return nil, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid request: %v", err)}
+			}
+		}
+		return fn(ctx, req)
+	})
+}
+
+// lookupAPI returns the handler registered under name.
+func (a *App) lookupAPI(name string) (APIFunc, bool) {
+	a.apiMutex.RLock()
+	defer a.apiMutex.RUnlock()
+	fn, ok := a.apiMethods[name]
+	return fn, ok
+}
+
+// registerCoreAPI registers the built-in methods used by gohta.js.
+func (a *App) registerCoreAPI() {
+	Register(a, "log", logMessage)
+	Register(a, "core.convertFileSrc", a.coreConvertFileSrc)
+	Register(a, "core.getArgs", a.coreGetArgs)
+	Register(a, "app.options", func(ctx context.Context, req struct{}) (ApplicationOptions, error) {
+		return a.appOptions, nil
+	})
+}
 
-	// Handle POST requests to /api/core/getArgs path
-	if r.URL.Path == "/api/core/getArgs" && r.Method == http.MethodGet {
-		handleGetArgsRequest(w, r)
+// API handler
+func (a *App) apiHandler(w http.ResponseWriter, r *http.Request) {
+	// /api/core/getArgs -> core.getArgs
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	name = strings.ReplaceAll(name, "/", ".")
+
+	fn, ok := a.lookupAPI(name)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown method: %s", name))
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		fmt.Fprintf(w, `{"message":"Hello from Go API!","method":"GET","path":"%s"}`, r.URL.Path)
-	case http.MethodPost:
-		// Other POST requests (not /api/log) can be handled here
-		fmt.Fprintf(w, `{"message":"Data received","method":"POST"}`)
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		fmt.Fprintf(w, `{"error":"Method not allowed","allowed":["GET","POST"]}`)
+	// Methods may have side effects, so links and image tags must not be able to call them
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return
 	}
-}
-
-// handleLogRequest processes POST requests to /api/log.
-func handleLogRequest(w http.ResponseWriter, r *http.Request) {
-	payload := struct {
-		Message string `json:"message"`
-	}{}
 
-	// Decode request body
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		log.Printf("❌  Error decoding log request: %v", err)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIRequestSize))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "could not read request body")
+		slog.Error("❌ Error reading request", "method", name, "error", err)
 		return
 	}
+	var params json.RawMessage
+	if len(strings.TrimSpace(string(body))) > 0 {
+		params = body
+	}
 
-	// Output log to server console
-	log.Printf("💻 [CLIENT] %s", payload.Message)
+	result, err := fn(r.Context(), params)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			writeJSONError(w, apiErr.Status, apiErr.Message)
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		slog.Error("❌ Error in API method", "method", name, "error", err)
+		return
+	}
 
-	// Send success response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	writeJSON(w, http.StatusOK, result)
 }
 
-// handleConvertFileSrcRequest processes POST requests to /api/convertFileSrc.
-func handleConvertFileSrcRequest(w http.ResponseWriter, r *http.Request) {
-	payload := struct {
-		FilePath string `json:"filePath"`
-	}{}
-
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		log.Printf("❌  Error decoding convertFileSrc request: %v", err)
-		return
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("❌ Error encoding JSON response", "error", err)
 	}
+}
 
-	// Remove file:// prefix and add /file/ prefix to create new source URL.
-	newSrc := convertFileSrc(payload.FilePath)
+// writeJSONError writes an error response of the form {"error": message}.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(newSrc)
+// logMessage outputs a message from the page to the server console.
+func logMessage(ctx context.Context, req struct {
+	Message string `json:"message"`
+}) (map[string]string, error) {
+	slog.Info("💻 [CLIENT]", "message", req.Message)
+	return map[string]string{"status": "ok"}, nil
 }
 
-// handleGetArgsRequest returns the command-line arguments passed to the application.
-func handleGetArgsRequest(w http.ResponseWriter, r *http.Request) {
-	var args []string
-	if staticMode {
-		// In static mode, all arguments after the executable are returned
-		args = os.Args[1:]
-	} else {
-		// In local mode, the first argument is the file path, so we return the rest
-		if len(os.Args) > 2 {
-			args = os.Args[2:]
-		} else {
-			args = []string{}
-		}
+// coreConvertFileSrc converts a file:// path to a URL served by the /file/ handler.
+func (a *App) coreConvertFileSrc(ctx context.Context, req struct {
+	FilePath string `json:"filePath"`
+}) (string, error) {
+	// Remove file:// prefix and add /file/ prefix to create new source URL.
+	newSrc, err := a.convertFileSrc(req.FilePath)
+	if err != nil {
+		slog.Warn("⚠️  convertFileSrc rejected a path", "path", req.FilePath, "error", err)
+		return "", &APIError{Status: http.StatusForbidden, Message: "file path not allowed"}
 	}
+	return newSrc, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(args)
+// coreGetArgs returns the application arguments from Options.Args.
+func (a *App) coreGetArgs(ctx context.Context, req struct{}) ([]string, error) {
+	if a.opts.Args == nil {
+		return []string{}, nil
+	}
+	return a.opts.Args, nil
 }
@@ -0,0 +1,54 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIHandlerAcceptsOnlyJSONPosts(t *testing.T) {
+	a := &App{apiMethods: make(map[string]APIFunc)}
+	called := 0
+	a.HandleAPI("app.quit", func(ctx context.Context, params json.RawMessage) (any, error) {
+		called++
+		return true, nil
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		want        int
+	}{
+		{"JSON post", "POST", "application/json", http.StatusOK},
+		{"JSON post with charset", "POST", "application/json; charset=utf-8", http.StatusOK},
+		{"get", "GET", "", http.StatusMethodNotAllowed},
+		{"form post", "POST", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text post", "POST", "text/plain", http.StatusUnsupportedMediaType},
+		{"post without content type", "POST", "", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = 0
+			r := httptest.NewRequest(tt.method, "/api/app/quit", strings.NewReader("{}"))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			a.apiHandler(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			wantCalls := 0
+			if tt.want == http.StatusOK {
+				wantCalls = 1
+			}
+			if called != wantCalls {
+				t.Errorf("method called %d times, want %d", called, wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package gohta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxWindowDimension bounds window sizes and positions from the gohta:application tag.
+const maxWindowDimension = 100000
+
+// ApplicationOptions holds the settings of the gohta:application tag, the declarative
+// way to configure an app like hta:application was.
+//
+//	<gohta:application title="Notes" width="800" height="600" windowstate="maximized"></gohta:application>
+type ApplicationOptions struct {
+	// ID identifies the app, overriding Options.ID.
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+	// Icon is the URL of the window icon, relative to the page.
+	Icon   string `json:"icon,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// X and Y are only used when HasPosition is set.
+	X           int  `json:"x,omitempty"`
+	Y           int  `json:"y,omitempty"`
+	HasPosition bool `json:"-"`
+	MinWidth    int  `json:"minWidth,omitempty"`
+	MinHeight   int  `json:"minHeight,omitempty"`
+	Kiosk       bool `json:"kiosk,omitempty"`
+	Fullscreen  bool `json:"fullscreen,omitempty"`
+	// SingleInstance forwards later launches to the running app.
+	SingleInstance bool `json:"singleInstance,omitempty"`
+	// Ephemeral uses a temporary Chrome profile instead of the persistent per-app one.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// WindowState is "normal" or "maximized".
+	WindowState string `json:"windowState,omitempty"`
+	// RememberWindow restores the window geometry of the last session over the
+	// settings above. It is on unless the tag sets rememberwindow="no".
+	RememberWindow bool     `json:"-"`
+	AllowedRoots   []string `json:"-"`
+	// ChromeFlags are extra command-line flags passed to Chrome.
+	ChromeFlags []string `json:"-"`
+}
+
+// parseApplicationTag reads the first gohta:application tag in htmlContent. Invalid values
+// are ignored and described in the returned warnings.
+func parseApplicationTag(htmlContent string) (ApplicationOptions, []string) {
+	opts := ApplicationOptions{RememberWindow: true}
+	var warnings []string
+	warnf := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		warnf("could not parse HTML to find gohta:application options: %v", err)
+		return opts, warnings
+	}
+	tag := findElement(doc, "gohta:application")
+	if tag == nil {
+		return opts, nil
+	}
+
+	// parseInt validates an attribute as an integer within [min, maxWindowDimension]
+	parseInt := func(key, value string, min int) (int, bool) {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < min || n > maxWindowDimension {
+			warnf("%s must be a number between %d and %d, got %q", key, min, maxWindowDimension, value)
+			return 0, false
+		}
+		return n, true
+	}
+	// parseBool accepts yes/no as in hta:application as well as true/false and 1/0
+	parseBool := func(key, value string) bool {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "yes", "true", "1", "":
+			return true
+		case "no", "false", "0":
+			return false
+		}
+		warnf("%s must be yes or no, got %q", key, value)
+		return false
+	}
+
+	var x, y *int
+	for _, a := range tag.Attr {
+		key := strings.ToLower(a.Key)
+		switch key {
+		case "id":
+			opts.ID = strings.TrimSpace(a.Val)
+		case "title":
+			opts.Title = a.Val
+		case "icon":
+			opts.Icon = a.Val
+		case "width":
+			opts.Width, _ = parseInt(key, a.Val, 1)
+		case "height":
+			opts.Height, _ = parseInt(key, a.Val, 1)
+		case "x":
+			if n, ok := parseInt(key, a.Val, -maxWindowDimension); ok {
+				x = &n
+			}
+		case "y":
+			if n, ok := parseInt(key, a.Val, -maxWindowDimension); ok {
+				y = &n
+			}
+		case "minwidth":
+			opts.MinWidth, _ = parseInt(key, a.Val, 1)
+		case "minheight":
+			opts.MinHeight, _ = parseInt(key, a.Val, 1)
+		case "kiosk":
+			opts.Kiosk = parseBool(key, a.Val)
+		case "fullscreen":
+			opts.Fullscreen = parseBool(key, a.Val)
+		case "singleinstance":
+			opts.SingleInstance = parseBool(key, a.Val)
+		case "ephemeral":
+			opts.Ephemeral = parseBool(key, a.Val)
+		case "windowstate":
+			switch state := strings.ToLower(strings.TrimSpace(a.Val)); state {
+			case "normal", "maximized":
+				opts.WindowState = state
+			default:
+				warnf("windowstate must be normal or maximized, got %q", a.Val)
+			}
+		case "rememberwindow":
+			opts.RememberWindow = parseBool(key, a.Val)
+		case "allowedroots":
+			opts.AllowedRoots = parseRootList(a.Val)
+		case "chromeflags":
+			for _, flag := range strings.Fields(a.Val) {
+				if !strings.HasPrefix(flag, "--") {
+					warnf("chrome flag must start with --, got %q", flag)
+					continue
+				}
+				if name, _, _ := strings.Cut(flag, "="); name == "--app" || name == "--user-data-dir" {
+					warnf("chrome flag %s is managed by gohta and cannot be set", name)
+					continue
+				}
+				opts.ChromeFlags = append(opts.ChromeFlags, flag)
+			}
+		default:
+			warnf("unknown gohta:application attribute %q", a.Key)
+		}
+	}
+
+	if (opts.Width == 0) != (opts.Height == 0) {
+		warnf("width and height must be set together")
+		opts.Width, opts.Height = 0, 0
+	}
+	if (x == nil) != (y == nil) {
+		warnf("x and y must be set together")
+	} else if x != nil {
+		opts.X, opts.Y, opts.HasPosition = *x, *y, true
+	}
+	return opts, warnings
+}
+
+// chromeArgs returns the Chrome flags that apply the window settings.
+func (o ApplicationOptions) chromeArgs() []string {
+	var args []string
+	if o.Width > 0 && o.Height > 0 {
+		args = append(args, fmt.Sprintf("--window-size=%d,%d", o.Width, o.Height))
+	}
+	if o.HasPosition {
+		args = append(args, fmt.Sprintf("--window-position=%d,%d", o.X, o.Y))
+	}
+	if o.Kiosk {
+		args = append(args, "--kiosk")
+	}
+	if o.Fullscreen {
+		args = append(args, "--start-fullscreen")
+	}
+	if o.WindowState == "maximized" {
+		args = append(args, "--start-maximized")
+	}
+	return append(args, o.ChromeFlags...)
+}
+
+// addHeadDefaults adds the title and icon from the gohta:application tag to head
+// unless the page defines its own.
+func (o ApplicationOptions) addHeadDefaults(head *html.Node) {
+	if o.Title != "" && findElement(head, "title") == nil {
+		titleNode := &html.Node{Type: html.ElementNode, Data: "title"}
+		titleNode.AppendChild(&html.Node{Type: html.TextNode, Data: o.Title})
+		head.AppendChild(titleNode)
+	}
+	if o.Icon != "" && !hasIconLink(head) {
+		head.AppendChild(&html.Node{
+			Type: html.ElementNode,
+			Data: "link",
+			Attr: []html.Attribute{{Key: "rel", Val: "icon"}, {Key: "href", Val: o.Icon}},
+		})
+	}
+}
+
+// hasIconLink reports whether head contains a <link rel="icon"> element.
+func hasIconLink(head *html.Node) bool {
+	for c := head.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "link" {
+			continue
+		}
+		for _, attr := range c.Attr {
+			if strings.ToLower(attr.Key) == "rel" && strings.Contains(strings.ToLower(attr.Val), "icon") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findElement returns the first element named name in the tree rooted at n.
+func findElement(n *html.Node, name string) *html.Node {
+	if n.Type == html.ElementNode && strings.ToLower(n.Data) == name {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package gohta
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseApplicationTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		want     ApplicationOptions
+		warnings []string // substrings of the expected warnings, in order
+	}{
+		{
+			name: "valid",
+			tag:  `<gohta:application title="Notes" width="800" height="600" x="-10" y="20" windowstate="Maximized" rememberwindow="no">`,
+			want: ApplicationOptions{Title: "Notes", Width: 800, Height: 600, X: -10, Y: 20, HasPosition: true, WindowState: "maximized"},
+		},
+		{
+			name:     "invalid numbers",
+			tag:      `<gohta:application width="wide" height="0" minwidth="200000" minheight="-1">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"width must be a number", "height must be a number", "minwidth must be a number", "minheight must be a number"},
+		},
+		{
+			name:     "width without height",
+			tag:      `<gohta:application width="800">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"width and height must be set together"},
+		},
+		{
+			name:     "height with an invalid width",
+			tag:      `<gohta:application width="-800" height="600">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"width must be a number", "width and height must be set together"},
+		},
+		{
+			name:     "x without y",
+			tag:      `<gohta:application x="100">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"x and y must be set together"},
+		},
+		{
+			name:     "y with an invalid x",
+			tag:      `<gohta:application x="left" y="100">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"x must be a number", "x and y must be set together"},
+		},
+		{
+			name:     "reserved chrome flags",
+			tag:      `<gohta:application chromeflags="--lang=de --app=https://example.com --user-data-dir=/tmp/x --user-data-dir incognito --disable-gpu">`,
+			want:     ApplicationOptions{RememberWindow: true, ChromeFlags: []string{"--lang=de", "--disable-gpu"}},
+			warnings: []string{"chrome flag --app is managed", "chrome flag --user-data-dir is managed", "chrome flag --user-data-dir is managed", `must start with --, got "incognito"`},
+		},
+		{
+			name:     "invalid boolean and state",
+			tag:      `<gohta:application kiosk="maybe" windowstate="minimized">`,
+			want:     ApplicationOptions{RememberWindow: true},
+			warnings: []string{"kiosk must be yes or no", "windowstate must be normal or maximized"},
+		},
+		{
+			name: "no tag",
+			tag:  `<title>Plain page</title>`,
+			want: ApplicationOptions{RememberWindow: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, warnings := parseApplicationTag("<html><head>" + tt.tag + "</head></html>")
+			if !reflect.DeepEqual(opts, tt.want) {
+				t.Errorf("got options %+v, want %+v", opts, tt.want)
+			}
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("got warnings %q, want %d", warnings, len(tt.warnings))
+			}
+			for i, want := range tt.warnings {
+				if !strings.Contains(warnings[i], want) {
+					t.Errorf("warning %q does not contain %q", warnings[i], want)
+				}
+			}
+		})
+	}
+}
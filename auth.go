@@ -0,0 +1,142 @@
+package gohta
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// launchQueryParam is the query parameter that carries a single-use launch code. The
+// code is exchanged for the token cookie, so the token itself never appears on a
+// command line, where other users can read it.
+const launchQueryParam = "gohta_launch"
+
+// tokenHeader lets non-browser clients send the token without a cookie.
+const tokenHeader = "X-Gohta-Token"
+
+// randomHex returns n random bytes as hex.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// initAuthToken mints a new per-launch token and derives the cookie name from the server port.
+// The cookie name includes the port because cookies are shared across ports on localhost.
+func (a *App) initAuthToken(port int) error {
+	token, err := randomHex(32)
+	if err != nil {
+		return fmt.Errorf("could not generate auth token: %w", err)
+	}
+	a.authToken = token
+	a.tokenCookieName = fmt.Sprintf("gohta_token_%d", port)
+	a.launchCodes = make(map[string]bool)
+	return nil
+}
+
+// launchURL returns a URL of the app with a new single-use launch code.
+func (a *App) launchURL(path string) (string, error) {
+	code, err := randomHex(16)
+	if err != nil {
+		return "", fmt.Errorf("could not generate launch code: %w", err)
+	}
+	a.launchCodesMutex.Lock()
+	a.launchCodes[code] = true
+	a.launchCodesMutex.Unlock()
+	return fmt.Sprintf("%s/app/%s?%s=%s", a.baseURL, path, launchQueryParam, url.QueryEscape(code)), nil
+}
+
+// useLaunchCode reports whether code is a launch code that has not been used, and
+// invalidates it.
+func (a *App) useLaunchCode(code string) bool {
+	a.launchCodesMutex.Lock()
+	defer a.launchCodesMutex.Unlock()
+	if !a.launchCodes[code] {
+		return false
+	}
+	delete(a.launchCodes, code)
+	return true
+}
+
+// validToken compares a candidate token with the launch token in constant time.
+func (a *App) validToken(candidate string) bool {
+	return candidate != "" && subtle.ConstantTimeCompare([]byte(candidate), []byte(a.authToken)) == 1
+}
+
+// hasValidToken reports whether the request carries the launch token in a header or cookie.
+func (a *App) hasValidToken(r *http.Request) bool {
+	if a.validToken(r.Header.Get(tokenHeader)) {
+		return true
+	}
+	cookie, err := r.Cookie(a.tokenCookieName)
+	return err == nil && a.validToken(cookie.Value)
+}
+
+// isSameOrigin reports whether a browser request comes from a page of the app. Cookies
+// are sent to every port of localhost, so a page of another local server could
+// otherwise use the token cookie.
+func (a *App) isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	}
+	return r.Header.Get("Origin") == a.baseURL
+}
+
+// RequireToken rejects requests that do not carry the launch token. A token sent in
+// the token cookie is only accepted from the app's own pages, while the token header,
+// which other sites cannot set, is accepted from anywhere.
+// Wrap routes added with Handle that must only be reachable from the app window.
+func (a *App) RequireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.validToken(r.Header.Get(tokenHeader)) {
+			cookie, err := r.Cookie(a.tokenCookieName)
+			if err != nil || !a.validToken(cookie.Value) {
+				slog.Warn("⚠️  Rejected request without valid token", "method", r.Method, "path", r.URL.Path)
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			if !a.isSameOrigin(r) {
+				slog.Warn("⚠️  Rejected request from another site", "method", r.Method, "path", r.URL.Path, "origin", r.Header.Get("Origin"))
+				writeJSONError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handleTokenBootstrap exchanges the launch code in the URL for the token cookie and
+// redirects to the same URL without the code. A browser that already has the cookie
+// is redirected even if the code was used. It returns true if the request was handled.
+func (a *App) handleTokenBootstrap(w http.ResponseWriter, r *http.Request) bool {
+	query := r.URL.Query()
+	if !query.Has(launchQueryParam) {
+		return false
+	}
+	if !a.useLaunchCode(query.Get(launchQueryParam)) && !a.hasValidToken(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		slog.Warn("⚠️  Rejected launch URL with an invalid or used launch code", "path", r.URL.Path)
+		return true
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.tokenCookieName,
+		Value:    a.authToken,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	query.Del(launchQueryParam)
+	target := *r.URL
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.RequestURI(), http.StatusFound)
+	return true
+}
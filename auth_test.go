@@ -0,0 +1,115 @@
+package gohta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestAuthApp(t *testing.T) *App {
+	t.Helper()
+	a := &App{baseURL: "http://localhost:1234"}
+	if err := a.initAuthToken(1234); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestLaunchCodeIsSingleUse(t *testing.T) {
+	a := newTestAuthApp(t)
+	launchURL, err := a.launchURL("index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(launchURL)
+	if parsed.Query().Get(launchQueryParam) == a.authToken {
+		t.Fatal("launch URL contains the token")
+	}
+
+	first := httptest.NewRecorder()
+	if !a.handleTokenBootstrap(first, httptest.NewRequest("GET", launchURL, nil)) || first.Code != http.StatusFound {
+		t.Fatalf("first use: status %d, want %d", first.Code, http.StatusFound)
+	}
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != a.authToken || !cookies[0].HttpOnly {
+		t.Fatalf("first use set cookies %v, want the HttpOnly token cookie", cookies)
+	}
+
+	second := httptest.NewRecorder()
+	a.handleTokenBootstrap(second, httptest.NewRequest("GET", launchURL, nil))
+	if second.Code != http.StatusForbidden {
+		t.Fatalf("second use: status %d, want %d", second.Code, http.StatusForbidden)
+	}
+
+	// The browser that exchanged the code may come back to the URL
+	withCookie := httptest.NewRequest("GET", launchURL, nil)
+	withCookie.AddCookie(cookies[0])
+	third := httptest.NewRecorder()
+	a.handleTokenBootstrap(third, withCookie)
+	if third.Code != http.StatusFound {
+		t.Fatalf("use with cookie: status %d, want %d", third.Code, http.StatusFound)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	a := newTestAuthApp(t)
+	handler := a.RequireToken(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusForbidden},
+		{"wrong token", "nope", http.StatusForbidden},
+		{"token header", a.authToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/os/info", nil)
+			if tt.header != "" {
+				r.Header.Set(tokenHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireTokenRejectsCookieFromOtherSites(t *testing.T) {
+	a := newTestAuthApp(t)
+	handler := a.RequireToken(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"same origin", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": a.baseURL}, http.StatusOK},
+		{"typed URL", map[string]string{"Sec-Fetch-Site": "none"}, http.StatusOK},
+		{"origin only", map[string]string{"Origin": a.baseURL}, http.StatusOK},
+		{"other localhost port", map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "http://localhost:8080"}, http.StatusForbidden},
+		{"other site", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://example.com"}, http.StatusForbidden},
+		{"no fetch metadata", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/shell/exec", strings.NewReader(`{"cmd":"id"}`))
+			r.Header.Set("Content-Type", "text/plain")
+			r.AddCookie(&http.Cookie{Name: a.tokenCookieName, Value: a.authToken})
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package gohta
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// browserEnvVar overrides browser detection with a browser name or executable path.
+const browserEnvVar = "GOHTA_BROWSER"
+
+// BrowserLauncher starts a Chromium-based browser. Tests can substitute a fake
+// launcher through Options.Launcher.
+type BrowserLauncher interface {
+	// Name identifies the browser in logs.
+	Name() string
+	// Launch starts the browser with Chrome command-line arguments and returns the started
+	// process. If files is not empty, args contains --remote-debugging-pipe and the
+	// browser must inherit files for DevTools: as file descriptors 3 and 4, or on Windows
+	// as the inheritable handles named in args.
+	Launch(args []string, files []*os.File) (*exec.Cmd, error)
+}
+
+// executableLauncher runs a browser executable directly.
+type executableLauncher struct {
+	name string
+	path string
+}
+
+func (l *executableLauncher) Name() string {
+	return fmt.Sprintf("%s (%s)", l.name, l.path)
+}
+
+func (l *executableLauncher) Launch(args []string, files []*os.File) (*exec.Cmd, error) {
+	cmd := exec.Command(l.path, args...)
+	inheritFiles(cmd, files)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// macOpenLauncher starts an application bundle with the macOS open command. open does
+// not pass files on, so the browser is started without DevTools.
+type macOpenLauncher struct {
+	app string
+}
+
+func (l *macOpenLauncher) Name() string {
+	return fmt.Sprintf("%s (open -a)", l.app)
+}
+
+func (l *macOpenLauncher) Launch(args []string, files []*os.File) (*exec.Cmd, error) {
+	openArgs := append([]string{"-n", "-a", l.app, "--args"}, args...)
+	cmd := exec.Command("open", openArgs...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// browserCandidate is a browser that detection looks for. Paths are absolute paths
+// or command names looked up in PATH, in order of preference.
+type browserCandidate struct {
+	name  string
+	paths []string
+}
+
+// browserCandidates lists the supported browsers for the current system in detection order.
+func browserCandidates() []browserCandidate {
+	switch runtime.GOOS {
+	case "windows":
+		return []browserCandidate{
+			{"chrome", []string{
+				os.ExpandEnv(`$ProgramFiles\Google\Chrome\Application\chrome.exe`),
+				os.ExpandEnv(`$ProgramFiles (x86)\Google\Chrome\Application\chrome.exe`),
+				os.ExpandEnv(`$LocalAppData\Google\Chrome\Application\chrome.exe`),
+				"chrome",
+			}},
+			{"edge", []string{
+				os.ExpandEnv(`$ProgramFiles (x86)\Microsoft\Edge\Application\msedge.exe`),
+				os.ExpandEnv(`$ProgramFiles\Microsoft\Edge\Application\msedge.exe`),
+				"msedge",
+			}},
+			{"brave", []string{
+				os.ExpandEnv(`$ProgramFiles\BraveSoftware\Brave-Browser\Application\brave.exe`),
+				os.ExpandEnv(`$LocalAppData\BraveSoftware\Brave-Browser\Application\brave.exe`),
+				"brave",
+			}},
+			{"chromium", []string{
+				os.ExpandEnv(`$LocalAppData\Chromium\Application\chrome.exe`),
+			}},
+		}
+	case "darwin":
+		return []browserCandidate{
+			{"chrome", []string{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"}},
+			{"chromium", []string{"/Applications/Chromium.app/Contents/MacOS/Chromium"}},
+			{"edge", []string{"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge"}},
+			{"brave", []string{"/Applications/Brave Browser.app/Contents/MacOS/Brave Browser"}},
+		}
+	default:
+		return []browserCandidate{
+			{"chrome", []string{"google-chrome", "google-chrome-stable"}},
+			{"chromium", []string{"chromium", "chromium-browser"}},
+			{"edge", []string{"microsoft-edge", "microsoft-edge-stable"}},
+			{"brave", []string{"brave-browser", "brave"}},
+		}
+	}
+}
+
+// resolveExecutable returns the executable for an absolute path or a command in PATH.
+func resolveExecutable(path string) (string, bool) {
+	if filepath.IsAbs(path) {
+		info, err := os.Stat(path)
+		return path, err == nil && !info.IsDir()
+	}
+	resolved, err := exec.LookPath(path)
+	return resolved, err == nil
+}
+
+// findBrowser detects an installed browser. override is a browser name such as
+// "chromium" or "edge", or the path of a browser executable. The error lists every
+// location that was tried.
+func findBrowser(override string) (BrowserLauncher, error) {
+	candidates := browserCandidates()
+	if override != "" {
+		var matching []browserCandidate
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate.name, override) {
+				matching = append(matching, candidate)
+			}
+		}
+		if len(matching) == 0 {
+			// Not a known name, so treat it as an executable
+			matching = []browserCandidate{{override, []string{override}}}
+		}
+		candidates = matching
+	}
+
+	var tried []string
+	for _, candidate := range candidates {
+		for _, path := range candidate.paths {
+			if resolved, ok := resolveExecutable(path); ok {
+				return &executableLauncher{name: candidate.name, path: resolved}, nil
+			}
+			tried = append(tried, path)
+		}
+	}
+
+	// Let macOS find Chrome wherever it is installed
+	if runtime.GOOS == "darwin" && override == "" {
+		return &macOpenLauncher{app: "Google Chrome"}, nil
+	}
+	return nil, fmt.Errorf("no supported browser found. Set %s to a browser executable. Tried:\n  %s", browserEnvVar, strings.Join(tried, "\n  "))
+}
+
+// devToolsPipe is the pair of pipes the browser uses for DevTools with
+// --remote-debugging-pipe. The browser reads commands from the first child file and
+// writes responses to the second.
+type devToolsPipe struct {
+	commandRead, commandWrite   *os.File
+	responseRead, responseWrite *os.File
+}
+
+func newDevToolsPipe() (*devToolsPipe, error) {
+	commandRead, commandWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	responseRead, responseWrite, err := os.Pipe()
+	if err != nil {
+		commandRead.Close()
+		commandWrite.Close()
+		return nil, err
+	}
+	return &devToolsPipe{commandRead, commandWrite, responseRead, responseWrite}, nil
+}
+
+// childFiles returns the ends of the pipes that the browser inherits.
+func (p *devToolsPipe) childFiles() []*os.File {
+	return []*os.File{p.commandRead, p.responseWrite}
+}
+
+// closeChildFiles closes the copies of the browser ends once the browser has them.
+func (p *devToolsPipe) closeChildFiles() {
+	p.commandRead.Close()
+	p.responseWrite.Close()
+}
+
+// close closes all ends, when the browser could not be started.
+func (p *devToolsPipe) close() {
+	p.closeChildFiles()
+	p.commandWrite.Close()
+	p.responseRead.Close()
+}
+
+// openChromeAppMode opens url in app mode with the given browser. files are passed to
+// the browser for DevTools if not empty.
+func openChromeAppMode(launcher BrowserLauncher, url string, tempDir string, extraArgs []string, files []*os.File) (*exec.Cmd, error) {
+	args := []string{
+		"--app=" + url,
+		"--user-data-dir=" + tempDir, // Use isolated profile
+		"--no-first-run",
+		"--no-default-browser-check",
+	}
+	if len(files) > 0 {
+		args = append(args, devToolsPipeArgs(files)...)
+	}
+	args = append(args, extraArgs...)
+
+	return launcher.Launch(args, files)
+}
+
+// launchBrowser opens url with Options.Launcher, or the browser selected by
+// Options.Browser, GOHTA_BROWSER or detection. It returns a DevTools client on the
+// pipes passed to the browser, or nil if the launcher cannot pass them on.
+func (a *App) launchBrowser(url string, profileDir string, extraArgs []string) (*exec.Cmd, *cdpClient, error) {
+	launcher := a.opts.Launcher
+	if launcher == nil {
+		override := a.opts.Browser
+		if override == "" {
+			override = os.Getenv(browserEnvVar)
+		}
+		var err error
+		if launcher, err = findBrowser(override); err != nil {
+			return nil, nil, err
+		}
+	}
+	slog.Info("🌐 Launching browser", "browser", launcher.Name())
+	// Later windows are opened with the same launcher
+	a.launcher = launcher
+
+	if _, ok := launcher.(*macOpenLauncher); ok {
+		cmd, err := openChromeAppMode(launcher, url, profileDir, extraArgs, nil)
+		return cmd, nil, err
+	}
+	pipe, err := newDevToolsPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create DevTools pipe: %w", err)
+	}
+	cmd, err := openChromeAppMode(launcher, url, profileDir, extraArgs, pipe.childFiles())
+	if err != nil {
+		pipe.close()
+		return nil, nil, err
+	}
+	pipe.closeChildFiles()
+	return cmd, newPipeCDP(pipe.commandWrite, pipe.responseRead), nil
+}
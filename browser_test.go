@@ -0,0 +1,109 @@
+package gohta
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeBrowserPath makes PATH hold only fake executables with the given names and
+// returns the directory. Detection on Windows and macOS uses install locations, so
+// the tests run on other systems only.
+func fakeBrowserPath(t *testing.T, names ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skipf("browser detection on %s does not search PATH", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func launcherPath(t *testing.T, launcher BrowserLauncher) string {
+	t.Helper()
+	executable, ok := launcher.(*executableLauncher)
+	if !ok {
+		t.Fatalf("launcher is %T, want *executableLauncher", launcher)
+	}
+	return executable.path
+}
+
+func TestFindBrowserOrder(t *testing.T) {
+	tests := []struct {
+		installed []string
+		want      string
+	}{
+		{[]string{"brave", "chromium", "google-chrome-stable"}, "google-chrome-stable"},
+		{[]string{"brave-browser", "microsoft-edge", "chromium-browser"}, "chromium-browser"},
+		{[]string{"brave", "microsoft-edge-stable"}, "microsoft-edge-stable"},
+		{[]string{"brave", "brave-browser"}, "brave-browser"},
+	}
+	for _, test := range tests {
+		dir := fakeBrowserPath(t, test.installed...)
+		launcher, err := findBrowser("")
+		if err != nil {
+			t.Fatalf("with %v installed: %v", test.installed, err)
+		}
+		if path := launcherPath(t, launcher); path != filepath.Join(dir, test.want) {
+			t.Errorf("with %v installed found %s, want %s", test.installed, path, test.want)
+		}
+	}
+}
+
+func TestFindBrowserOverride(t *testing.T) {
+	dir := fakeBrowserPath(t, "google-chrome", "microsoft-edge", "custom-browser")
+
+	launcher, err := findBrowser("Edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := launcherPath(t, launcher); path != filepath.Join(dir, "microsoft-edge") {
+		t.Errorf("override by name found %s, want microsoft-edge", path)
+	}
+
+	custom := filepath.Join(dir, "custom-browser")
+	launcher, err = findBrowser(custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := launcherPath(t, launcher); path != custom {
+		t.Errorf("override by path found %s, want %s", path, custom)
+	}
+
+	// An override that is not installed does not fall back to detection
+	if launcher, err := findBrowser("brave"); err == nil {
+		t.Errorf("override with a missing browser found %s", launcher.Name())
+	}
+}
+
+func TestFindBrowserListsTriedLocations(t *testing.T) {
+	fakeBrowserPath(t)
+
+	_, err := findBrowser("")
+	if err == nil {
+		t.Fatal("found a browser in an empty PATH")
+	}
+	message := err.Error()
+	if !strings.Contains(message, browserEnvVar) || !strings.Contains(message, "Tried:") {
+		t.Errorf("error %q does not explain how to choose a browser", message)
+	}
+	for _, candidate := range browserCandidates() {
+		for _, path := range candidate.paths {
+			if !strings.Contains(message, "\n  "+path) {
+				t.Errorf("error does not list %s:\n%s", path, message)
+			}
+		}
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing-browser")
+	if _, err := findBrowser(missing); err == nil || !strings.Contains(err.Error(), "Tried:\n  "+missing) {
+		t.Errorf("override with a missing path returned %v, want it listed as tried", err)
+	}
+}
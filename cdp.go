@@ -0,0 +1,200 @@
+package gohta
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// errCDPClosed is returned for calls on a closed CDP connection.
+var errCDPClosed = errors.New("DevTools connection closed")
+
+type cdpRequest struct {
+	ID     int64  `json:"id"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type cdpResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *cdpError       `json:"error"`
+}
+
+type cdpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *cdpError) Error() string {
+	return fmt.Sprintf("DevTools error %d: %s", e.Code, e.Message)
+}
+
+// cdpTransport carries DevTools messages, each one JSON object.
+type cdpTransport interface {
+	readMessage() ([]byte, error)
+	writeMessage(data []byte) error
+	close() error
+}
+
+// wsTransport carries DevTools messages over a WebSocket.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsTransport) readMessage() ([]byte, error) {
+	_, data, err := t.conn.ReadMessage()
+	return data, err
+}
+
+func (t *wsTransport) writeMessage(data []byte) error {
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (t *wsTransport) close() error {
+	return t.conn.Close()
+}
+
+// pipeTransport carries DevTools messages over the pipes of --remote-debugging-pipe,
+// where each message ends with a NUL byte. Unlike a debugging port, the pipes are only
+// reachable by the process that started the browser.
+type pipeTransport struct {
+	reader   *bufio.Reader
+	response io.Closer
+	command  io.WriteCloser
+}
+
+func (t *pipeTransport) readMessage() ([]byte, error) {
+	data, err := t.reader.ReadBytes(0)
+	if err != nil {
+		return nil, err
+	}
+	return data[:len(data)-1], nil
+}
+
+func (t *pipeTransport) writeMessage(data []byte) error {
+	_, err := t.command.Write(append(data, 0))
+	return err
+}
+
+func (t *pipeTransport) close() error {
+	t.command.Close()
+	return t.response.Close()
+}
+
+// cdpClient is a minimal Chrome DevTools Protocol client. It sends commands to the
+// browser and matches responses by ID. Events are ignored.
+type cdpClient struct {
+	transport  cdpTransport
+	writeMutex sync.Mutex
+
+	nextID       int64
+	pending      map[int64]chan cdpResponse
+	pendingMutex sync.Mutex
+
+	closed chan struct{}
+}
+
+// newCDPClient starts a client on transport.
+func newCDPClient(transport cdpTransport) *cdpClient {
+	c := &cdpClient{
+		transport: transport,
+		pending:   make(map[int64]chan cdpResponse),
+		closed:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// dialCDP connects to a DevTools WebSocket URL such as ws://127.0.0.1:9222/devtools/browser/<id>.
+// gohta talks to its own browser over pipes; this is for endpoints opened by other means.
+func dialCDP(ctx context.Context, url string) (*cdpClient, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newCDPClient(&wsTransport{conn: conn}), nil
+}
+
+// newPipeCDP starts a client that writes commands to command and reads responses from response.
+func newPipeCDP(command io.WriteCloser, response io.ReadCloser) *cdpClient {
+	return newCDPClient(&pipeTransport{reader: bufio.NewReader(response), response: response, command: command})
+}
+
+// readLoop delivers responses to waiting calls until the connection closes.
+func (c *cdpClient) readLoop() {
+	defer close(c.closed)
+	for {
+		data, err := c.transport.readMessage()
+		if err != nil {
+			return
+		}
+		var resp cdpResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			continue
+		}
+		if resp.ID == 0 {
+			// Events have no ID
+			continue
+		}
+		c.pendingMutex.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.pendingMutex.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// Call sends a command and decodes its result into result, which may be nil.
+func (c *cdpClient) Call(ctx context.Context, method string, params any, result any) error {
+	ch := make(chan cdpResponse, 1)
+	c.pendingMutex.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.pendingMutex.Unlock()
+	defer func() {
+		c.pendingMutex.Lock()
+		delete(c.pending, id)
+		c.pendingMutex.Unlock()
+	}()
+
+	data, err := json.Marshal(cdpRequest{ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	c.writeMutex.Lock()
+	err = c.transport.writeMessage(data)
+	c.writeMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-c.closed:
+		return errCDPClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the connection.
+func (c *cdpClient) Close() error {
+	return c.transport.close()
+}
@@ -0,0 +1,158 @@
+package gohta
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeCDPServer starts a DevTools WebSocket endpoint that passes every command to
+// handle and writes the responses it returns.
+func fakeCDPServer(t *testing.T, handle func(conn *websocket.Conn, req cdpRequest)) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req cdpRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			handle(conn, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestCDPCallMatchesResponsesByID(t *testing.T) {
+	// Hold the first command back and answer it after the second, along with an event
+	var held *cdpRequest
+	url := fakeCDPServer(t, func(conn *websocket.Conn, req cdpRequest) {
+		if held == nil {
+			held = &req
+			return
+		}
+		conn.WriteJSON(map[string]any{"method": "Target.targetCreated", "params": map[string]any{}})
+		for _, r := range []cdpRequest{req, *held} {
+			conn.WriteJSON(map[string]any{"id": r.ID, "result": map[string]string{"method": r.Method}})
+		}
+	})
+	client, err := dialCDP(testContext(t), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	results := make(chan string, 2)
+	call := func(method string) {
+		var result struct {
+			Method string `json:"method"`
+		}
+		if err := client.Call(testContext(t), method, nil, &result); err != nil {
+			t.Error(err)
+		}
+		results <- method + "=" + result.Method
+	}
+	go call("First.method")
+	time.Sleep(50 * time.Millisecond)
+	go call("Second.method")
+
+	got := map[string]bool{<-results: true, <-results: true}
+	for _, want := range []string{"First.method=First.method", "Second.method=Second.method"} {
+		if !got[want] {
+			t.Errorf("missing %s in %v", want, got)
+		}
+	}
+}
+
+func TestCDPCallReturnsErrors(t *testing.T) {
+	url := fakeCDPServer(t, func(conn *websocket.Conn, req cdpRequest) {
+		conn.WriteJSON(map[string]any{"id": req.ID, "error": map[string]any{"code": -32601, "message": "'Nope.method' wasn't found"}})
+	})
+	client, err := dialCDP(testContext(t), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	err = client.Call(testContext(t), "Nope.method", nil, nil)
+	var cdpErr *cdpError
+	if !errors.As(err, &cdpErr) || cdpErr.Code != -32601 {
+		t.Fatalf("Call() error = %v, want a cdpError with code -32601", err)
+	}
+}
+
+func TestCDPCallFailsWhenConnectionCloses(t *testing.T) {
+	url := fakeCDPServer(t, func(conn *websocket.Conn, req cdpRequest) {
+		conn.Close()
+	})
+	client, err := dialCDP(testContext(t), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Call(testContext(t), "Browser.close", nil, nil); !errors.Is(err, errCDPClosed) {
+		t.Fatalf("Call() error = %v, want errCDPClosed", err)
+	}
+	if err := client.Call(testContext(t), "Browser.getVersion", nil, nil); err == nil {
+		t.Fatal("Call() on a closed connection succeeded")
+	}
+}
+
+func TestCDPOverPipes(t *testing.T) {
+	commandRead, commandWrite, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	responseRead, responseWrite, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Play the browser: read NUL-terminated commands and answer them the same way
+	go func() {
+		defer responseWrite.Close()
+		reader := bufio.NewReader(commandRead)
+		for {
+			data, err := reader.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			var req cdpRequest
+			json.Unmarshal(data[:len(data)-1], &req)
+			response, _ := json.Marshal(map[string]any{"id": req.ID, "result": map[string]string{"product": "Chrome/1.0"}})
+			responseWrite.Write(append(response, 0))
+		}
+	}()
+
+	client := newPipeCDP(commandWrite, responseRead)
+	defer client.Close()
+	var version struct {
+		Product string `json:"product"`
+	}
+	if err := client.Call(testContext(t), "Browser.getVersion", nil, &version); err != nil {
+		t.Fatal(err)
+	}
+	if version.Product != "Chrome/1.0" {
+		t.Fatalf("product = %q, want Chrome/1.0", version.Product)
+	}
+}
@@ -0,0 +1,228 @@
+package gohta
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// errClipboardUnsupported is returned when a clipboard cannot handle a data type.
+var errClipboardUnsupported = errors.New("operation not supported by this clipboard")
+
+// Clipboard reads and writes the system clipboard. Images are PNG encoded.
+type Clipboard interface {
+	ReadText() (string, error)
+	WriteText(text string) error
+	ReadImage() ([]byte, error)
+	WriteImage(png []byte) error
+}
+
+// MemoryClipboard keeps clipboard contents in memory, for use in tests.
+type MemoryClipboard struct {
+	mutex sync.Mutex
+	text  string
+	image []byte
+}
+
+func (c *MemoryClipboard) ReadText() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.text, nil
+}
+
+func (c *MemoryClipboard) WriteText(text string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.text = text
+	return nil
+}
+
+func (c *MemoryClipboard) ReadImage() ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.image, nil
+}
+
+func (c *MemoryClipboard) WriteImage(png []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.image = png
+	return nil
+}
+
+// commandClipboard uses external tools such as wl-copy, xclip, pbcopy or PowerShell.
+// A nil command means the operation is unsupported.
+type commandClipboard struct {
+	name       string
+	readText   []string
+	writeText  []string
+	readImage  []string
+	writeImage []string
+	// trimCRLF removes the line break PowerShell adds to the output of readText
+	trimCRLF bool
+}
+
+// read executes command and returns its output.
+func (c *commandClipboard) read(command []string) ([]byte, error) {
+	if command == nil {
+		return nil, errClipboardUnsupported
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// write executes command with data as stdin. Output is not captured because tools
+// like xclip keep running in the background to own the selection.
+func (c *commandClipboard) write(command []string, data []byte) error {
+	if command == nil {
+		return errClipboardUnsupported
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v", command[0], err)
+	}
+	return nil
+}
+
+func (c *commandClipboard) ReadText() (string, error) {
+	output, err := c.read(c.readText)
+	if c.trimCRLF {
+		output = bytes.TrimSuffix(output, []byte("\r\n"))
+	}
+	return string(output), err
+}
+
+func (c *commandClipboard) WriteText(text string) error {
+	return c.write(c.writeText, []byte(text))
+}
+
+func (c *commandClipboard) ReadImage() ([]byte, error) {
+	return c.read(c.readImage)
+}
+
+func (c *commandClipboard) WriteImage(png []byte) error {
+	return c.write(c.writeImage, png)
+}
+
+// detectClipboard finds a clipboard tool for the current system.
+func detectClipboard() (*commandClipboard, bool) {
+	hasCommand := func(name string) bool {
+		_, err := exec.LookPath(name)
+		return err == nil
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return &commandClipboard{
+			name:      "powershell",
+			readText:  []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+			writeText: []string{"powershell", "-NoProfile", "-Command", "[Console]::In.ReadToEnd() | Set-Clipboard"},
+			trimCRLF:  true,
+		}, true
+	case "darwin":
+		return &commandClipboard{
+			name:      "pbcopy",
+			readText:  []string{"pbpaste"},
+			writeText: []string{"pbcopy"},
+		}, true
+	}
+
+	// Linux and other Unix systems
+	if os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand("wl-copy") && hasCommand("wl-paste") {
+		return &commandClipboard{
+			name:       "wl-clipboard",
+			readText:   []string{"wl-paste", "--no-newline"},
+			writeText:  []string{"wl-copy"},
+			readImage:  []string{"wl-paste", "--type", "image/png"},
+			writeImage: []string{"wl-copy", "--type", "image/png"},
+		}, true
+	}
+	if hasCommand("xclip") {
+		return &commandClipboard{
+			name:       "xclip",
+			readText:   []string{"xclip", "-selection", "clipboard", "-out"},
+			writeText:  []string{"xclip", "-selection", "clipboard", "-in"},
+			readImage:  []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-out"},
+			writeImage: []string{"xclip", "-selection", "clipboard", "-target", "image/png", "-in"},
+		}, true
+	}
+	if hasCommand("xsel") {
+		return &commandClipboard{
+			name:      "xsel",
+			readText:  []string{"xsel", "--clipboard", "--output"},
+			writeText: []string{"xsel", "--clipboard", "--input"},
+		}, true
+	}
+	return nil, false
+}
+
+// initClipboard uses Options.Clipboard or detects the system clipboard.
+func (a *App) initClipboard() {
+	if a.opts.Clipboard != nil {
+		a.clipboard = a.opts.Clipboard
+		return
+	}
+	if clipboard, ok := detectClipboard(); ok {
+		slog.Info("📋 Using clipboard tool", "tool", clipboard.name)
+		a.clipboard = clipboard
+		return
+	}
+	slog.Warn("⚠️  No clipboard tool found (install wl-clipboard, xclip or xsel). gohta.clipboard is unavailable.")
+	a.clipboard = &commandClipboard{name: "none"}
+}
+
+// clipboardError reports unsupported operations as 501 Not Implemented.
+func clipboardError(err error) error {
+	if errors.Is(err, errClipboardUnsupported) {
+		return &APIError{Status: http.StatusNotImplemented, Message: err.Error()}
+	}
+	return err
+}
+
+// registerClipboardAPI registers the clipboard.* methods used by gohta.clipboard in gohta.js.
+// Images are transferred as base64 encoded PNG data.
+func (a *App) registerClipboardAPI() {
+	Register(a, "clipboard.readText", func(ctx context.Context, req struct{}) (string, error) {
+		text, err := a.clipboard.ReadText()
+		return text, clipboardError(err)
+	})
+	Register(a, "clipboard.writeText", func(ctx context.Context, req struct {
+		Text string `json:"text"`
+	}) (bool, error) {
+		err := a.clipboard.WriteText(req.Text)
+		return err == nil, clipboardError(err)
+	})
+	Register(a, "clipboard.readImage", func(ctx context.Context, req struct{}) (string, error) {
+		png, err := a.clipboard.ReadImage()
+		if err != nil {
+			return "", clipboardError(err)
+		}
+		return base64.StdEncoding.EncodeToString(png), nil
+	})
+	Register(a, "clipboard.writeImage", func(ctx context.Context, req struct {
+		Data string `json:"data"`
+	}) (bool, error) {
+		png, err := base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			return false, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid base64 data: %v", err)}
+		}
+		err = a.clipboard.WriteImage(png)
+		return err == nil, clipboardError(err)
+	})
+}
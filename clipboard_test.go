@@ -0,0 +1,80 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"testing"
+)
+
+// callAPI calls a registered API method with params encoded as JSON.
+func callAPI(t *testing.T, a *App, name string, params any) (any, error) {
+	t.Helper()
+	fn, ok := a.lookupAPI(name)
+	if !ok {
+		t.Fatalf("method %s is not registered", name)
+	}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fn(context.Background(), encoded)
+}
+
+func newTestClipboardApp(clipboard Clipboard) *App {
+	a := &App{apiMethods: make(map[string]APIFunc), clipboard: clipboard}
+	a.registerClipboardAPI()
+	return a
+}
+
+func TestClipboardAPI(t *testing.T) {
+	a := newTestClipboardApp(&MemoryClipboard{})
+
+	if _, err := callAPI(t, a, "clipboard.writeText", map[string]string{"text": "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if text, err := callAPI(t, a, "clipboard.readText", struct{}{}); err != nil || text != "Hello" {
+		t.Errorf("readText returned %v, %v, want Hello", text, err)
+	}
+
+	if _, err := callAPI(t, a, "clipboard.writeImage", map[string]string{"data": "iVBORw=="}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := callAPI(t, a, "clipboard.readImage", struct{}{}); err != nil || data != "iVBORw==" {
+		t.Errorf("readImage returned %v, %v, want iVBORw==", data, err)
+	}
+
+	_, err := callAPI(t, a, "clipboard.writeImage", map[string]string{"data": "not base64"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("writeImage with invalid data returned %v, want a 400 error", err)
+	}
+}
+
+func TestClipboardWithoutToolIsUnsupported(t *testing.T) {
+	a := newTestClipboardApp(&commandClipboard{name: "none"})
+
+	for _, method := range []string{"clipboard.readText", "clipboard.readImage"} {
+		_, err := callAPI(t, a, method, struct{}{})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotImplemented {
+			t.Errorf("%s returned %v, want a 501 error", method, err)
+		}
+	}
+}
+
+func TestCommandClipboardTrimsCRLF(t *testing.T) {
+	if _, err := exec.LookPath("printf"); err != nil {
+		t.Skip("printf is not available")
+	}
+	clipboard := &commandClipboard{readText: []string{"printf", `line\r\n\r\n`}, trimCRLF: true}
+	text, err := clipboard.ReadText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "line\r\n" {
+		t.Errorf("ReadText returned %q, want %q", text, "line\r\n")
+	}
+}
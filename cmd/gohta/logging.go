@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// setupLogging sends log output to logFile, or stderr if empty, and drops records
+// below level ("debug", "info", "warn" or "error"). gohta logs with the default slog
+// logger, which writes through the log package until slog.SetDefault is called.
+func setupLogging(logFile string, level string) error {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q, use debug, info, warn or error", level)
+	}
+
+	var out io.Writer = os.Stderr
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		out = file
+	}
+	log.SetOutput(out)
+	slog.SetLogLoggerLevel(minLevel)
+	return nil
+}
+
+// fatal logs an error and exits with status 1.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,202 @@
+// Command gohta opens an HTML file or directory as a desktop app, or serves the
+// site embedded from the static directory when static/index.html exists.
+//
+// Usage:
+//
+//	gohta [flags] <path-to-html-file-or-directory> [-- app args...]
+//
+// Arguments after -- are passed verbatim to the app and returned by gohta.core.getArgs().
+//
+// gohta exits with the status passed to gohta.app.quit(code) in the page, otherwise with
+// 0 when the app is closed or stopped by SIGINT or SIGTERM, 1 when it fails and 2 for
+// invalid usage. On SIGINT or SIGTERM the pages are asked before the app closes and can
+// cancel; a second signal closes it regardless.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	"github.com/tobwithu/gohta"
+)
+
+//go:embed static/**
+var staticFS embed.FS
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+func main() {
+	port := flag.Int("port", 0, "port of the local server (default: a free port)")
+	bind := flag.String("bind", "localhost", "address the server listens on")
+	browser := flag.String("browser", "", "browser name (chrome, chromium, edge, brave) or executable path; overrides GOHTA_BROWSER")
+	noBrowser := flag.Bool("no-browser", false, "serve the app without opening a browser window")
+	serve := flag.Bool("serve", false, "same as --no-browser")
+	runtimeFile := flag.String("runtime-file", "", "write the URL, port, token and PID to this JSON file while running")
+	profile := flag.String("profile", "", "Chrome profile directory (default: a persistent per-app profile)")
+	width := flag.Int("width", 0, "initial window width")
+	height := flag.Int("height", 0, "initial window height")
+	logFile := flag.String("log-file", "", "append log output to this file")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	dev := flag.Bool("dev", false, "enable development mode with live reload")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: gohta [flags] <path-to-html-file-or-directory> [-- app args...]")
+		flag.PrintDefaults()
+	}
+
+	positional, appArgs := parseArgs(os.Args[1:])
+
+	if *showVersion {
+		fmt.Println("gohta", buildVersion())
+		return
+	}
+	if (*width > 0) != (*height > 0) || *width < 0 || *height < 0 {
+		fmt.Fprintln(os.Stderr, "--width and --height must be set together")
+		os.Exit(2)
+	}
+	if err := setupLogging(*logFile, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opts := gohta.Options{
+		Browser:     *browser,
+		NoBrowser:   *noBrowser || *serve,
+		RuntimeFile: *runtimeFile,
+		ProfileDir:  *profile,
+		Port:        *port,
+		Bind:        *bind,
+		Width:       *width,
+		Height:      *height,
+		Dev:         *dev,
+	}
+
+	// Check if static/index.html exists and serve from the embedded assets
+	if _, err := staticFS.Open("static/index.html"); err == nil {
+		slog.Info("💡 Found static/index.html. Serving from embedded static assets.")
+		subFS, err := fs.Sub(staticFS, "static")
+		if err != nil {
+			fatal("❌ Failed to create sub-filesystem for static assets", "error", err)
+		}
+		opts.FS = subFS
+		opts.ID = strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+		// In static mode, there is no path argument, so all arguments are app arguments
+		opts.Args = append(positional, appArgs...)
+	} else {
+		if len(positional) < 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		htmlFilePath := positional[0]
+		// In local mode, the first argument is the file path, so the rest are app arguments
+		opts.Args = append(positional[1:], appArgs...)
+
+		info, err := os.Stat(htmlFilePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				fatal("❌ Input path does not exist", "path", htmlFilePath)
+			}
+			fatal("❌ Error checking input path", "error", err)
+		}
+
+		if info.IsDir() {
+			if _, err := os.Stat(filepath.Join(htmlFilePath, "index.html")); err != nil {
+				fatal("❌ index.html not found in directory", "error", err)
+			}
+			opts.Root = htmlFilePath
+			opts.ID = appIDFromPath(htmlFilePath)
+		} else {
+			opts.Root = filepath.Dir(htmlFilePath)
+			opts.Entry = info.Name()
+			opts.ID = appIDFromPath(htmlFilePath)
+		}
+	}
+
+	app, err := gohta.New(opts)
+	if err != nil {
+		fatal("❌ Could not create the app", "error", err)
+	}
+
+	// Stop the app when an interrupt or termination signal is received. Pages may cancel
+	// the shutdown, and a second signal forces it.
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		<-signals
+		stop()
+		slog.Info("💡 Press Ctrl+C again to quit without waiting for the app.")
+		<-signals
+		app.ForceQuit(0)
+	}()
+
+	if err := app.Run(ctx); err != nil && !errors.Is(err, gohta.ErrAlreadyRunning) {
+		fatal("❌ App failed", "error", err)
+	}
+	stop()
+	os.Exit(app.ExitCode())
+}
+
+// parseArgs parses the flags in args, which may come before or after the path, and
+// returns the positional arguments and the arguments after --, which are left verbatim.
+func parseArgs(args []string) (positional []string, appArgs []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			args, appArgs = args[:i], args[i+1:]
+			break
+		}
+	}
+	if appArgs == nil {
+		appArgs = []string{}
+	}
+
+	for {
+		// Parse exits with usage on invalid flags
+		flag.CommandLine.Parse(args)
+		args = flag.Args()
+		if len(args) == 0 {
+			return positional, appArgs
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// buildVersion returns the version set at build time, or the module version.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// appIDFromPath derives the app ID from the absolute path of an HTML file or directory:
+// its name without extension and a short hash of the path, so that apps with the same
+// file name in different directories get their own store and profile.
+func appIDFromPath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSuffix(filepath.Base(absPath), filepath.Ext(absPath))
+	hash := sha256.Sum256([]byte(absPath))
+	return name + "-" + hex.EncodeToString(hash[:4])
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppIDFromPath(t *testing.T) {
+	dir := t.TempDir()
+	first := appIDFromPath(filepath.Join(dir, "a", "index.html"))
+	second := appIDFromPath(filepath.Join(dir, "b", "index.html"))
+
+	if !strings.HasPrefix(first, "index-") || !strings.HasPrefix(second, "index-") {
+		t.Errorf("IDs %q and %q do not start with the file name", first, second)
+	}
+	if first == second {
+		t.Errorf("files with the same name in different directories share the ID %q", first)
+	}
+	if again := appIDFromPath(filepath.Join(dir, "a", "index.html")); again != first {
+		t.Errorf("ID changed from %q to %q for the same path", first, again)
+	}
+}
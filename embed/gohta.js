@@ -1,26 +1,263 @@
-const post = async (url, body = {}) => {
-  const response = await fetch(`/api/${url}`, {
+// GohtaError is thrown by failed calls. code is the JSON-RPC error code and status
+// the HTTP status reported by the API method, if any.
+class GohtaError extends Error {
+  constructor(message, { code, status } = {}) {
+    super(message)
+    this.name = "GohtaError"
+    this.code = code
+    this.status = status
+  }
+}
+
+const request = async (url, options) => {
+  const response = await fetch(`/api/${url}`, options)
+  if (!response.ok) {
+    let message = `HTTP error! status: ${response.status}`
+    try {
+      const body = await response.json()
+      if (body && body.error) {
+        message = body.error
+      }
+    } catch (error) {
+      // Keep the generic message if the body is not JSON
+    }
+    throw new GohtaError(message, { status: response.status })
+  }
+  return response.json()
+}
+
+// httpCall calls an API method with an HTTP POST. It is the fallback of rpc.call.
+const httpCall = async (method, params = {}) => {
+  return request(method.replaceAll(".", "/"), {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
     },
-    body: JSON.stringify(body),
+    body: JSON.stringify(params),
   })
-  if (!response.ok) {
-    throw new Error(`HTTP error! status: ${response.status}`)
-  }
-  return response.json()
+}
+
+// post and get call the API method at a path such as "fs/readFile"
+const post = async (url, body = {}) => {
+  return rpc.call(url.replaceAll("/", "."), body)
 }
 
 const get = async (url) => {
-  const response = await fetch(`/api/${url}`)
-  if (!response.ok) {
-    throw new Error(`HTTP error! status: ${response.status}`)
+  return rpc.call(url.replaceAll("/", "."))
+}
+
+const bytesToBase64 = (bytes) => {
+  let binary = ""
+  for (let i = 0; i < bytes.length; i += 0x8000) {
+    binary += String.fromCharCode(...bytes.subarray(i, i + 0x8000))
+  }
+  return btoa(binary)
+}
+
+const base64ToBytes = (base64) => {
+  const binary = atob(base64)
+  const bytes = new Uint8Array(binary.length)
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i)
+  }
+  return bytes
+}
+
+// randomId returns 128 random bits as hex. crypto.randomUUID is missing in pages that
+// are not a secure context, such as an app opened from another machine over --bind.
+const randomId = () => {
+  const bytes = crypto.getRandomValues(new Uint8Array(16))
+  return Array.from(bytes, (byte) => byte.toString(16).padStart(2, "0")).join("")
+}
+
+// encodeFileData converts a string, ArrayBuffer or typed array to a write request payload.
+const encodeFileData = (data) => {
+  if (typeof data === "string") {
+    return { data, encoding: "utf8" }
+  }
+  const bytes = data instanceof ArrayBuffer
+    ? new Uint8Array(data)
+    : new Uint8Array(data.buffer, data.byteOffset, data.byteLength)
+  return { data: bytesToBase64(bytes), encoding: "base64" }
+}
+
+// windowId identifies this app window. Windows opened with gohta.window.open get it in
+// their URL, and sessionStorage keeps it while the window navigates.
+const windowId = (() => {
+  const id = new URLSearchParams(window.location.search).get("gohta_window") ||
+    sessionStorage.getItem("gohta_window") || "main"
+  sessionStorage.setItem("gohta_window", id)
+  return id
+})()
+
+// rpc calls API methods with JSON-RPC 2.0 over one WebSocket, opened on first use,
+// which also carries server events as notifications. Calls use HTTP when the
+// WebSocket cannot be opened.
+const rpc = {
+  socket: null,
+  connecting: null,
+  unavailable: false,
+  nextId: 1,
+  pending: new Map(),
+  connect() {
+    if (this.connecting) return this.connecting
+    this.connecting = new Promise((resolve, reject) => {
+      const protocol = window.location.protocol === "https:" ? "wss:" : "ws:"
+      const ws = new WebSocket(`${protocol}//${window.location.host}/ws/rpc?window=${encodeURIComponent(windowId)}`)
+      let opened = false
+      ws.onopen = () => {
+        opened = true
+        this.socket = ws
+        this.unavailable = false
+        resolve(ws)
+      }
+      ws.onmessage = (message) => this.receive(JSON.parse(message.data))
+      ws.onclose = () => {
+        this.connecting = null
+        this.socket = null
+        for (const { reject } of this.pending.values()) {
+          reject(new GohtaError("connection to the app closed"))
+        }
+        this.pending.clear()
+        // The server stops the watches of a closed connection
+        fileWatches.callbacks.clear()
+        if (!opened) {
+          this.unavailable = true
+          reject(new GohtaError("could not connect to the app"))
+        }
+        // Reconnect while someone is listening for events or quits
+        if (events.handlers.size > 0 || beforeQuitHandlers.size > 0) {
+          setTimeout(() => this.connect().catch(() => {}), 1000)
+        }
+      }
+    })
+    return this.connecting
+  },
+  receive(message) {
+    if (message.id === undefined) {
+      // Notifications carry events
+      events.dispatch(message.method, message.params)
+      return
+    }
+    if (message.method) {
+      this.serve(message)
+      return
+    }
+    const call = this.pending.get(message.id)
+    if (!call) return
+    this.pending.delete(message.id)
+    if (message.error) {
+      const { code, message: text, data } = message.error
+      call.reject(new GohtaError(text, { code, status: data && data.status }))
+    } else {
+      call.resolve(message.result)
+    }
+  },
+  // serve answers a call from the server with the result of the handler in methods
+  async serve({ id, method, params }) {
+    const reply = (response) => {
+      if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+        this.socket.send(JSON.stringify({ jsonrpc: "2.0", id, ...response }))
+      }
+    }
+    const handler = this.methods[method]
+    if (!handler) {
+      reply({ error: { code: -32601, message: `method not found: ${method}` } })
+      return
+    }
+    try {
+      reply({ result: await handler(params) })
+    } catch (error) {
+      reply({ error: { code: -32603, message: String(error && error.message || error) } })
+    }
+  },
+  // methods holds the handlers of calls from the server
+  methods: {
+    // The app asks every page before it quits. Any handler returning false cancels.
+    async "app.beforeQuit"(params) {
+      for (const handler of [...beforeQuitHandlers]) {
+        try {
+          if (await handler(params) === false) return false
+        } catch (error) {
+          console.error("Error in beforeQuit handler:", error)
+        }
+      }
+      return true
+    }
+  },
+  async call(method, params = {}) {
+    if (this.unavailable) {
+      return httpCall(method, params)
+    }
+    let ws
+    try {
+      ws = await this.connect()
+    } catch {
+      return httpCall(method, params)
+    }
+    const id = this.nextId++
+    return new Promise((resolve, reject) => {
+      this.pending.set(id, { resolve, reject })
+      ws.send(JSON.stringify({ jsonrpc: "2.0", id, method, params }))
+    })
   }
-  return response.json()
+}
+
+// beforeQuitHandlers are registered with gohta.app.onBeforeQuit
+const beforeQuitHandlers = new Set()
+
+// events dispatches server events to the handlers registered with gohta.on.
+const events = {
+  handlers: new Map(),
+  dispatch(event, payload) {
+    for (const handler of this.handlers.get(event) || []) {
+      try {
+        handler(payload)
+      } catch (error) {
+        console.error(`Error in ${event} handler:`, error)
+      }
+    }
+  },
+  on(event, handler) {
+    if (!this.handlers.has(event)) {
+      this.handlers.set(event, new Set())
+    }
+    this.handlers.get(event).add(handler)
+    // Events arrive over the RPC connection
+    rpc.connect().catch(() => {})
+    return () => this.off(event, handler)
+  },
+  off(event, handler) {
+    const handlers = this.handlers.get(event)
+    if (!handlers) return
+    handlers.delete(handler)
+    if (handlers.size === 0) {
+      this.handlers.delete(event)
+    }
+  }
+}
+
+// fileWatches maps the IDs of watches started with gohta.fs.watch to their callbacks.
+// Watches end when the RPC connection closes.
+const fileWatches = {
+  callbacks: new Map(),
+  listening: false
 }
 
 const gohta = {
+  // GohtaError is the class of errors thrown by failed calls
+  GohtaError,
+  async invoke(name, args = {}) {
+    return rpc.call(name, args)
+  },
+  // on calls handler with the payload of every event sent by the Go side with
+  // app.Emit or app.EmitTo. Returns a function that removes the handler.
+  on(event, handler) {
+    return events.on(event, handler)
+  },
+  off(event, handler) {
+    events.off(event, handler)
+  },
   async log(message) {
     try {
       await post("log", { message })
@@ -29,18 +266,402 @@ const gohta = {
     }
   },
   core:{
+    // convertFileSrc rejects if the file is outside the allowed roots
     async convertFileSrc(filePath) {
-        try {
-          const result = await post("core/convertFileSrc", { filePath })
-          return result
-        } catch (error) {
-          console.error("Error converting file src:", error)
-          return filePath
-        }
-      },
+      return post("core/convertFileSrc", { filePath })
+    },
     async getArgs(){
       const result = await get("core/getArgs")
       return result
     }
+  },
+  app: {
+    // onSecondInstance calls handler with { args, argv, cwd } when the app is launched
+    // again in single-instance mode. Returns a function that removes the handler.
+    onSecondInstance(handler) {
+      return events.on("second-instance", handler)
+    },
+    // quit closes all windows and stops the app, which exits with code. Handlers
+    // registered with onBeforeQuit in any window can cancel it.
+    async quit(code = 0) {
+      return post("app/quit", { code })
+    },
+    // onBeforeQuit calls handler with { code } before the app quits. Returning false, or
+    // a promise that resolves to false, cancels the quit, for example to keep unsaved
+    // work. Returns a function that removes the handler.
+    onBeforeQuit(handler) {
+      beforeQuitHandlers.add(handler)
+      // The question arrives over the RPC connection
+      rpc.connect().catch(() => {})
+      return () => beforeQuitHandlers.delete(handler)
+    }
+  },
+  fs: {
+    // encoding is "utf8" (returns a string) or "binary" (returns a Uint8Array)
+    async readFile(path, { encoding = "utf8" } = {}) {
+      if (encoding === "binary") {
+        return base64ToBytes(await post("fs/readFile", { path, encoding: "base64" }))
+      }
+      return post("fs/readFile", { path, encoding })
+    },
+    async writeFile(path, data) {
+      return post("fs/writeFile", { path, ...encodeFileData(data) })
+    },
+    async appendFile(path, data) {
+      return post("fs/appendFile", { path, ...encodeFileData(data) })
+    },
+    async readDir(path) {
+      return post("fs/readDir", { path })
+    },
+    async stat(path) {
+      return post("fs/stat", { path })
+    },
+    async mkdir(path, { recursive = false } = {}) {
+      return post("fs/mkdir", { path, recursive })
+    },
+    async remove(path, { recursive = false } = {}) {
+      return post("fs/remove", { path, recursive })
+    },
+    async rename(from, to) {
+      return post("fs/rename", { from, to })
+    },
+    async exists(path) {
+      return post("fs/exists", { path })
+    },
+    // watch calls callback with [{ path, op }] when files change, where op is "create",
+    // "write", "remove" or "rename". Changes are batched. filter is a glob pattern such
+    // as "*.log" matched against file names. Resolves to a handle with close().
+    async watch(path, { recursive = false, filter = "" } = {}, callback) {
+      if (!fileWatches.listening) {
+        fileWatches.listening = true
+        events.on("fs.watch", ({ id, events: changes }) => {
+          const handler = fileWatches.callbacks.get(id)
+          if (handler) handler(changes)
+        })
+      }
+      const id = await post("fs/watch", { path, recursive, filter })
+      fileWatches.callbacks.set(id, callback)
+      return {
+        id,
+        async close() {
+          if (!fileWatches.callbacks.delete(id)) return
+          await post("fs/unwatch", { id })
+        }
+      }
+    }
+  },
+  shell: {
+    // exec runs a process to completion and resolves to { code, stdout, stderr }
+    async exec(cmd, args = [], { cwd, env, stdin } = {}) {
+      return post("shell/exec", { cmd, args, cwd, env, stdin })
+    },
+    // spawn streams output to onStdout/onStderr while the process runs.
+    // The returned handle's done promise resolves to the exit code.
+    spawn(cmd, args = [], { cwd, env, stdin, onStdout, onStderr } = {}) {
+      const protocol = window.location.protocol === "https:" ? "wss:" : "ws:"
+      const ws = new WebSocket(`${protocol}//${window.location.host}/ws/shell`)
+      const send = (message) => ws.send(JSON.stringify(message))
+      const whenOpen = (fn) => {
+        if (ws.readyState === WebSocket.OPEN) {
+          fn()
+        } else {
+          ws.addEventListener("open", fn, { once: true })
+        }
+      }
+
+      let finished = false
+      let resolveDone, rejectDone
+      const done = new Promise((resolve, reject) => {
+        resolveDone = resolve
+        rejectDone = reject
+      })
+
+      ws.onopen = () => {
+        send({ cmd, args, cwd, env })
+        if (stdin !== undefined) {
+          send({ type: "stdin", data: stdin })
+          send({ type: "closeStdin" })
+        }
+      }
+      ws.onmessage = (event) => {
+        const message = JSON.parse(event.data)
+        switch (message.type) {
+          case "stdout":
+            if (onStdout) onStdout(message.data)
+            break
+          case "stderr":
+            if (onStderr) onStderr(message.data)
+            break
+          case "exit":
+            finished = true
+            resolveDone(message.code)
+            break
+          case "error":
+            finished = true
+            rejectDone(new Error(message.message))
+            break
+        }
+      }
+      ws.onclose = () => {
+        if (!finished) {
+          rejectDone(new Error("Connection to process closed"))
+        }
+      }
+
+      return {
+        done,
+        write(data) {
+          whenOpen(() => send({ type: "stdin", data }))
+        },
+        end() {
+          whenOpen(() => send({ type: "closeStdin" }))
+        },
+        kill() {
+          whenOpen(() => send({ type: "kill" }))
+        }
+      }
+    }
+  },
+  // The window methods act on this window unless the ID of another window is passed last
+  window: {
+    // id is the ID of this window. The window opened at launch is "main".
+    id: windowId,
+    async setTitle(title, id = windowId) {
+      if (id === windowId) {
+        document.title = title
+        return true
+      }
+      return post("window/setTitle", { window: id, title })
+    },
+    // getBounds resolves to { left, top, width, height, windowState }
+    async getBounds(id = windowId) {
+      return post("window/getBounds", { window: id })
+    },
+    async resize(width, height, id = windowId) {
+      return post("window/resize", { window: id, width, height })
+    },
+    async move(x, y, id = windowId) {
+      return post("window/move", { window: id, x, y })
+    },
+    async minimize(id = windowId) {
+      return post("window/minimize", { window: id })
+    },
+    async maximize(id = windowId) {
+      return post("window/maximize", { window: id })
+    },
+    async restore(id = windowId) {
+      return post("window/restore", { window: id })
+    },
+    async fullscreen(id = windowId) {
+      return post("window/fullscreen", { window: id })
+    },
+    async focus(id = windowId) {
+      return post("window/focus", { window: id })
+    },
+    async close(id = windowId) {
+      return post("window/close", { window: id })
+    },
+    // open opens path, relative to the app root, in a new window and resolves to its ID
+    async open(path, { width, height, title } = {}) {
+      return post("window/open", { path, width, height, title })
+    },
+    // list resolves to the open windows as [{ id, path, title }]
+    async list() {
+      return get("window/list")
+    },
+    // postMessage sends data to the window with the given ID, or to all windows for "*"
+    async postMessage(target, data) {
+      return post("window/postMessage", { from: windowId, target, data })
+    },
+    // onMessage calls handler with { from, data } for messages sent to this window.
+    // Returns a function that removes the handler.
+    onMessage(handler) {
+      return events.on("window.message", handler)
+    }
+  },
+  // tasks are long-running jobs started by Go API methods with app.StartTask, which
+  // return the task ID
+  tasks: {
+    // list resolves to the tasks as [{ id, name, status, done, total, error, startedAt }].
+    // status is "running", "completed", "failed" or "cancelled".
+    async list() {
+      return get("tasks/list")
+    },
+    async get(id) {
+      return post("tasks/get", { id })
+    },
+    // cancel cancels the context of the task. Resolves to false if it already finished.
+    async cancel(id) {
+      return post("tasks/cancel", { id })
+    },
+    // watch calls onProgress with { done, total } and onLog with each output line of the
+    // task. Resolves to the task when it completes and rejects with a GohtaError if it
+    // fails or is cancelled.
+    watch(id, { onProgress, onLog } = {}) {
+      return new Promise((resolve, reject) => {
+        let finished = false
+        const finish = (task, error) => {
+          if (finished) return
+          finished = true
+          offs.forEach((off) => off())
+          if (error) {
+            reject(error)
+          } else if (task.status === "completed") {
+            resolve(task)
+          } else {
+            reject(new GohtaError(task.error || `task ${task.status}`))
+          }
+        }
+        const offs = [
+          events.on("task.progress", (progress) => {
+            if (progress.id === id && onProgress) onProgress(progress)
+          }),
+          events.on("task.log", ({ id: taskId, line }) => {
+            if (taskId === id && onLog) onLog(line)
+          }),
+          events.on("task.finished", (task) => {
+            if (task.id === id) finish(task)
+          })
+        ]
+        // The task may have finished before the handlers were registered
+        post("tasks/get", { id }).then((task) => {
+          if (task.status !== "running") finish(task)
+        }, (error) => finish(null, error))
+      })
+    }
+  },
+  store: {
+    async get(key) {
+      return post("store/get", { key })
+    },
+    async set(key, value) {
+      return post("store/set", { key, value })
+    },
+    async delete(key) {
+      return post("store/delete", { key })
+    },
+    async keys() {
+      return get("store/keys")
+    },
+    async clear() {
+      return post("store/clear")
+    },
+    // onChange calls handler with { key, value } after every change. value is null for removed keys.
+    // Returns a function that removes the handler.
+    onChange(handler) {
+      return events.on("store.change", handler)
+    }
+  },
+  clipboard: {
+    async readText() {
+      return get("clipboard/readText")
+    },
+    async writeText(text) {
+      return post("clipboard/writeText", { text })
+    },
+    // readImage resolves to a PNG Blob
+    async readImage() {
+      const data = await get("clipboard/readImage")
+      return new Blob([base64ToBytes(data)], { type: "image/png" })
+    },
+    // writeImage accepts PNG data as a Blob, ArrayBuffer or typed array
+    async writeImage(image) {
+      if (image instanceof Blob) {
+        image = await image.arrayBuffer()
+      }
+      return post("clipboard/writeImage", { data: encodeFileData(image).data })
+    }
+  },
+  os: {
+    // info resolves to { platform, arch, hostname, username, homeDir, tempDir, configDir, cacheDir }
+    async info() {
+      return get("os/info")
+    },
+    // env resolves to the allowed environment variables
+    async env() {
+      return get("os/env")
+    },
+    async getEnv(name) {
+      return post("os/getEnv", { name })
+    },
+    // folders resolves to { home, desktop, documents, downloads, music, pictures, videos, ... }
+    async folders() {
+      return get("os/folders")
+    }
+  }
+}
+
+// Apply window behavior from the gohta:application tag
+get("app/options").then(({ minWidth, minHeight, singleInstance }) => {
+  // Bring the window to the front when the app is launched again
+  if (singleInstance && windowId === "main") {
+    events.on("second-instance", () => window.focus())
   }
-}
\ No newline at end of file
+
+  // Keep the window at least minWidth x minHeight
+  if (!minWidth && !minHeight) return
+  const enforceMinSize = () => {
+    const width = Math.max(window.outerWidth, minWidth || 0)
+    const height = Math.max(window.outerHeight, minHeight || 0)
+    if (width !== window.outerWidth || height !== window.outerHeight) {
+      window.resizeTo(width, height)
+    }
+  }
+  window.addEventListener("resize", enforceMinSize)
+  enforceMinSize()
+}).catch((error) => {
+  console.error("Error loading app options:", error)
+})
+
+// Apply the title set by another window with gohta.window.setTitle
+events.on("window.setTitle", ({ title }) => {
+  document.title = title
+})
+
+// Apply the title requested with gohta.window.open
+const requestedTitle = new URLSearchParams(window.location.search).get("gohta_title")
+if (requestedTitle) {
+  window.addEventListener("DOMContentLoaded", () => {
+    document.title = requestedTitle
+  })
+}
+
+// Tell the server this page is open. The app shuts down shortly after the last page stops
+// sending heartbeats. The timer runs in a worker because browsers throttle timers in
+// hidden and minimized windows.
+const heartbeat = {
+  pageId: randomId(),
+  intervalMs: 2000,
+  send() {
+    httpCall("app.heartbeat", {
+      pageId: heartbeat.pageId,
+      windowId,
+      path: window.location.pathname.replace(/^\/app\//, ""),
+      title: document.title
+    }).catch(() => {})
+  },
+  start() {
+    heartbeat.send()
+    try {
+      const source = `setInterval(() => postMessage(0), ${heartbeat.intervalMs})`
+      const worker = new Worker(URL.createObjectURL(new Blob([source], { type: "text/javascript" })))
+      worker.onmessage = heartbeat.send
+    } catch {
+      setInterval(heartbeat.send, heartbeat.intervalMs)
+    }
+  }
+}
+heartbeat.start()
+window.addEventListener("pagehide", () => {
+  // keepalive lets the request outlive the page, like sendBeacon, but with a JSON body
+  fetch("/api/app/goodbye", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ pageId: heartbeat.pageId }),
+    keepalive: true
+  }).catch(() => {})
+})
+window.addEventListener("pageshow", (event) => {
+  // Pages restored from the back/forward cache said goodbye when they were hidden
+  if (event.persisted) heartbeat.send()
+})
@@ -0,0 +1,121 @@
+package gohta
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventWriteTimeout bounds how long sending a message to one page may take.
+const eventWriteTimeout = 5 * time.Second
+
+// wsUpgrader upgrades WebSocket requests from pages. The default origin check rejects other sites.
+var wsUpgrader = websocket.Upgrader{}
+
+// eventClient is a page connected to /ws/rpc. Writes are serialized per connection.
+type eventClient struct {
+	conn       *websocket.Conn
+	windowID   string
+	done       <-chan struct{} // closed when the page disconnects
+	writeMutex sync.Mutex
+
+	// Calls from the server waiting for a response from the page
+	calls      map[int64]chan rpcRequest
+	nextCallID int64
+	callsMutex sync.Mutex
+}
+
+// writeJSON sends v to the page.
+func (c *eventClient) writeJSON(v any) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	// A page that stops reading must not block the sender
+	c.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	return c.conn.WriteJSON(v)
+}
+
+// eventHub tracks connected pages and pushes events to them.
+type eventHub struct {
+	clients map[*eventClient]bool
+	mutex   sync.RWMutex
+}
+
+// newEventHub creates an empty event hub
+func newEventHub() *eventHub {
+	return &eventHub{
+		clients: make(map[*eventClient]bool),
+	}
+}
+
+// add registers a connected page.
+func (h *eventHub) add(client *eventClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[client] = true
+}
+
+// remove unregisters a page that disconnected.
+func (h *eventHub) remove(client *eventClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.clients, client)
+}
+
+// broadcast sends an event to all connected pages
+func (h *eventHub) broadcast(event string, payload any) {
+	h.send("", event, payload)
+}
+
+// connected returns the pages of a window, or all pages if windowID is empty.
+func (h *eventHub) connected(windowID string) []*eventClient {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	clients := make([]*eventClient, 0, len(h.clients))
+	for client := range h.clients {
+		if windowID == "" || client.windowID == windowID {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// send sends an event to the pages of a window, or to all pages if windowID is empty.
+// Events are JSON-RPC notifications named after the event.
+func (h *eventHub) send(windowID string, event string, payload any) {
+	for _, client := range h.connected(windowID) {
+		if err := client.writeJSON(rpcNotification{JSONRPC: "2.0", Method: event, Params: payload}); err != nil {
+			slog.Error("❌ Error sending event", "event", event, "error", err)
+			client.conn.Close()
+		}
+	}
+}
+
+// closeAll closes the connections of all pages and waits up to timeout for the calls
+// they were running to finish.
+func (h *eventHub) closeAll(timeout time.Duration) {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "app is closing")
+	for _, client := range h.connected("") {
+		client.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+		client.conn.Close()
+	}
+
+	deadline := time.Now().Add(timeout)
+	for len(h.connected("")) > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Emit sends an event to all pages of the app. payload is encoded as JSON and passed
+// to the handlers registered with gohta.on(event, handler). Pages that are not
+// listening to any event do not receive it.
+func (a *App) Emit(event string, payload any) {
+	a.events.broadcast(event, payload)
+}
+
+// EmitTo sends an event to the pages of one window, such as "main" or an ID returned
+// by gohta.window.open.
+func (a *App) EmitTo(windowID string, event string, payload any) {
+	a.events.send(windowID, event, payload)
+}
@@ -0,0 +1,295 @@
+package gohta
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fsPathRequest is the request of file system methods that take a single path.
+type fsPathRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+}
+
+// fsReadFileRequest reads a file as "utf8" text (the default) or as "base64" encoded bytes.
+type fsReadFileRequest struct {
+	Path     string `json:"path"`
+	Encoding string `json:"encoding"`
+}
+
+// fsWriteFileRequest writes Data, which is "utf8" text (the default) or "base64" encoded bytes.
+type fsWriteFileRequest struct {
+	Path     string `json:"path"`
+	Data     string `json:"data"`
+	Encoding string `json:"encoding"`
+}
+
+type fsRenameRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// fsFileInfo describes a file returned by fs.stat and fs.readDir.
+type fsFileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	IsDir   bool      `json:"isDir"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// registerFSAPI registers the fs.* methods used by gohta.fs in gohta.js.
+func (a *App) registerFSAPI() {
+	Register(a, "fs.readFile", a.fsReadFile)
+	Register(a, "fs.writeFile", func(ctx context.Context, req fsWriteFileRequest) (bool, error) {
+		return a.fsWrite(req, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	})
+	Register(a, "fs.appendFile", func(ctx context.Context, req fsWriteFileRequest) (bool, error) {
+		return a.fsWrite(req, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	})
+	Register(a, "fs.readDir", a.fsReadDir)
+	Register(a, "fs.stat", a.fsStat)
+	Register(a, "fs.mkdir", a.fsMkdir)
+	Register(a, "fs.remove", a.fsRemove)
+	Register(a, "fs.rename", a.fsRename)
+	Register(a, "fs.exists", a.fsExists)
+}
+
+// fsPath resolves a path from the page and checks it against the allowed roots.
+// Relative paths are resolved against the app directory.
+func (a *App) fsPath(path string) (string, error) {
+	path, err := a.fsAbsPath(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := a.checkAllowedPath(path)
+	if err != nil {
+		return "", fsError(err)
+	}
+	return resolved, nil
+}
+
+// fsEntryPath is like fsPath, but resolves only the parent directory, so that the path
+// names a symlink itself instead of its target.
+func (a *App) fsEntryPath(path string) (string, error) {
+	path, err := a.fsAbsPath(path)
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Clean(path)
+	parent, base := filepath.Dir(path), filepath.Base(path)
+	if parent == path {
+		return "", &APIError{Status: http.StatusForbidden, Message: errPathNotAllowed.Error()}
+	}
+	resolvedParent, err := a.checkAllowedPath(parent)
+	if err != nil {
+		return "", fsError(err)
+	}
+	return filepath.Join(resolvedParent, base), nil
+}
+
+// fsWritePath returns the file a write to path goes to. A symlink is followed only if
+// its target exists inside the allowed roots.
+func (a *App) fsWritePath(path string) (string, error) {
+	entry, err := a.fsEntryPath(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(entry)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return entry, nil
+	}
+	target, err := filepath.EvalSymlinks(entry)
+	if err != nil {
+		// A dangling link would create its target wherever it points
+		return "", &APIError{Status: http.StatusForbidden, Message: fmt.Sprintf("%s is a symlink to a missing file", path)}
+	}
+	resolved, err := a.checkAllowedPath(target)
+	if err != nil {
+		return "", fsError(err)
+	}
+	return resolved, nil
+}
+
+// fsAbsPath makes a path from the page absolute without resolving symlinks.
+// Relative paths are resolved against the app directory.
+func (a *App) fsAbsPath(path string) (string, error) {
+	if path == "" {
+		return "", &APIError{Status: http.StatusBadRequest, Message: "path is required"}
+	}
+	if strings.HasPrefix(path, "file://") {
+		path = localPathFromURL(path)
+	}
+	if !filepath.IsAbs(path) && a.opts.Root != "" {
+		path = filepath.Join(a.opts.Root, path)
+	}
+	return filepath.Abs(path)
+}
+
+// fsError converts file system errors to API errors with a matching HTTP status.
+func fsError(err error) error {
+	switch {
+	case errors.Is(err, errPathNotAllowed), errors.Is(err, os.ErrPermission):
+		return &APIError{Status: http.StatusForbidden, Message: err.Error()}
+	case errors.Is(err, os.ErrNotExist):
+		return &APIError{Status: http.StatusNotFound, Message: err.Error()}
+	case errors.Is(err, os.ErrExist):
+		return &APIError{Status: http.StatusConflict, Message: err.Error()}
+	}
+	return err
+}
+
+// newFSFileInfo converts os.FileInfo to the JSON form returned to the page.
+func newFSFileInfo(info os.FileInfo) fsFileInfo {
+	return fsFileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		IsDir:   info.IsDir(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime(),
+	}
+}
+
+func (a *App) fsReadFile(ctx context.Context, req fsReadFileRequest) (string, error) {
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fsError(err)
+	}
+
+	switch req.Encoding {
+	case "", "utf8":
+		return string(data), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return "", &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("unknown encoding: %s", req.Encoding)}
+}
+
+// fsWrite writes or appends req.Data depending on flag.
+func (a *App) fsWrite(req fsWriteFileRequest, flag int) (bool, error) {
+	path, err := a.fsWritePath(req.Path)
+	if err != nil {
+		return false, err
+	}
+
+	var data []byte
+	switch req.Encoding {
+	case "", "utf8":
+		data = []byte(req.Data)
+	case "base64":
+		data, err = base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			return false, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid base64 data: %v", err)}
+		}
+	default:
+		return false, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("unknown encoding: %s", req.Encoding)}
+	}
+
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return false, fsError(err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return false, err
+	}
+	return true, file.Close()
+}
+
+func (a *App) fsReadDir(ctx context.Context, req fsPathRequest) ([]fsFileInfo, error) {
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fsError(err)
+	}
+
+	infos := make([]fsFileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// The entry was removed while listing
+			continue
+		}
+		infos = append(infos, newFSFileInfo(info))
+	}
+	return infos, nil
+}
+
+func (a *App) fsStat(ctx context.Context, req fsPathRequest) (fsFileInfo, error) {
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return fsFileInfo{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fsFileInfo{}, fsError(err)
+	}
+	return newFSFileInfo(info), nil
+}
+
+func (a *App) fsMkdir(ctx context.Context, req fsPathRequest) (bool, error) {
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return false, err
+	}
+	if req.Recursive {
+		err = os.MkdirAll(path, 0755)
+	} else {
+		err = os.Mkdir(path, 0755)
+	}
+	return err == nil, fsError(err)
+}
+
+// fsRemove removes a symlink itself, not its target.
+func (a *App) fsRemove(ctx context.Context, req fsPathRequest) (bool, error) {
+	path, err := a.fsEntryPath(req.Path)
+	if err != nil {
+		return false, err
+	}
+	if req.Recursive {
+		err = os.RemoveAll(path)
+	} else {
+		err = os.Remove(path)
+	}
+	return err == nil, fsError(err)
+}
+
+// fsRename moves a symlink itself, and replaces a symlink at req.To instead of its target.
+func (a *App) fsRename(ctx context.Context, req fsRenameRequest) (bool, error) {
+	from, err := a.fsEntryPath(req.From)
+	if err != nil {
+		return false, err
+	}
+	to, err := a.fsEntryPath(req.To)
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(from, to)
+	return err == nil, fsError(err)
+}
+
+func (a *App) fsExists(ctx context.Context, req fsPathRequest) (bool, error) {
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, fsError(err)
+}
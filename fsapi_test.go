@@ -0,0 +1,116 @@
+package gohta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestFSApp returns an app whose only allowed root is a new directory "root", next
+// to a directory "outside" that is not allowed.
+func newTestFSApp(t *testing.T) (a *App, root string, outside string) {
+	t.Helper()
+	dir := t.TempDir()
+	root = filepath.Join(dir, "root")
+	outside = filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a = &App{}
+	a.opts.Root = root
+	if err := a.AddAllowedRoot(root, ""); err != nil {
+		t.Fatal(err)
+	}
+	return a, root, outside
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestFSRemoveRemovesSymlink(t *testing.T) {
+	a, root, outside := newTestFSApp(t)
+	target := filepath.Join(root, "target.txt")
+	writeFile(t, target)
+	symlink(t, target, filepath.Join(root, "link.txt"))
+	outsideTarget := filepath.Join(outside, "secret.txt")
+	writeFile(t, outsideTarget)
+	symlink(t, outsideTarget, filepath.Join(root, "outside-link.txt"))
+
+	for _, name := range []string{"link.txt", "outside-link.txt"} {
+		if _, err := a.fsRemove(context.Background(), fsPathRequest{Path: name}); err != nil {
+			t.Fatalf("removing %s: %v", name, err)
+		}
+		if exists(filepath.Join(root, name)) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	if !exists(target) || !exists(outsideTarget) {
+		t.Error("removing a symlink removed its target")
+	}
+}
+
+func TestFSRenameMovesSymlink(t *testing.T) {
+	a, root, outside := newTestFSApp(t)
+	outsideTarget := filepath.Join(outside, "secret.txt")
+	writeFile(t, outsideTarget)
+	symlink(t, outsideTarget, filepath.Join(root, "link.txt"))
+
+	if _, err := a.fsRename(context.Background(), fsRenameRequest{From: "link.txt", To: "moved.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filepath.Join(root, "moved.txt"))
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("moved.txt is not the symlink: %v", err)
+	}
+	if !exists(outsideTarget) {
+		t.Error("renaming a symlink moved its target")
+	}
+}
+
+func TestFSWriteThroughSymlink(t *testing.T) {
+	a, root, outside := newTestFSApp(t)
+	target := filepath.Join(root, "target.txt")
+	writeFile(t, target)
+	symlink(t, target, filepath.Join(root, "link.txt"))
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(root, "dangling.txt"))
+	symlink(t, outside, filepath.Join(root, "outside-dir"))
+
+	if _, err := a.fsWrite(fsWriteFileRequest{Path: "link.txt", Data: "new"}, os.O_WRONLY|os.O_TRUNC); err != nil {
+		t.Fatalf("writing through a link inside the root: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("target contains %q, want %q", data, "new")
+	}
+
+	for _, name := range []string{"dangling.txt", "outside-dir/secret.txt"} {
+		_, err := a.fsWrite(fsWriteFileRequest{Path: name, Data: "new"}, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+			t.Errorf("writing %s: got %v, want a 403 error", name, err)
+		}
+	}
+	if exists(filepath.Join(outside, "secret.txt")) {
+		t.Error("a write created a file outside the allowed roots")
+	}
+}
@@ -0,0 +1,220 @@
+package gohta
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a watch collects changes before sending them as one batch.
+const watchDebounce = 100 * time.Millisecond
+
+type fsWatchRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+	Filter    string `json:"filter"`
+}
+
+type fsUnwatchRequest struct {
+	ID string `json:"id"`
+}
+
+// fsWatchEvent is one change reported to the page. Op is "create", "write", "remove" or "rename".
+type fsWatchEvent struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+}
+
+// fsWatchNotification is the params of the fs.watch notification sent to the page
+// that started the watch.
+type fsWatchNotification struct {
+	ID     string         `json:"id"`
+	Events []fsWatchEvent `json:"events"`
+}
+
+// fileWatch is a watch started by a page with gohta.fs.watch.
+type fileWatch struct {
+	id        string
+	client    *eventClient
+	watcher   *fsnotify.Watcher
+	recursive bool
+	filter    string
+	stop      chan struct{}
+}
+
+// registerFSWatchAPI registers fs.watch and fs.unwatch. Watches deliver changes over the
+// RPC connection of the page and stop when it disconnects.
+func (a *App) registerFSWatchAPI() {
+	Register(a, "fs.watch", a.fsWatch)
+	Register(a, "fs.unwatch", func(ctx context.Context, req fsUnwatchRequest) (bool, error) {
+		client, _ := rpcClientFrom(ctx)
+		a.watchesMutex.Lock()
+		watch, ok := a.watches[req.ID]
+		if ok && watch.client == client {
+			delete(a.watches, req.ID)
+		}
+		a.watchesMutex.Unlock()
+		if !ok || watch.client != client {
+			return false, &APIError{Status: http.StatusNotFound, Message: fmt.Sprintf("no watch with ID %q", req.ID)}
+		}
+		close(watch.stop)
+		return true, nil
+	})
+}
+
+// fsWatch starts watching a file or directory and returns the ID of the watch.
+func (a *App) fsWatch(ctx context.Context, req fsWatchRequest) (string, error) {
+	client, ok := rpcClientFrom(ctx)
+	if !ok {
+		return "", &APIError{Status: http.StatusBadRequest, Message: "fs.watch is only available over the RPC WebSocket"}
+	}
+	if req.Filter != "" {
+		if _, err := filepath.Match(req.Filter, ""); err != nil {
+			return "", &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid filter %q: %v", req.Filter, err)}
+		}
+	}
+	path, err := a.fsPath(req.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fsError(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return "", err
+	}
+	watch := &fileWatch{
+		client:    client,
+		watcher:   watcher,
+		recursive: req.Recursive && info.IsDir(),
+		filter:    req.Filter,
+		stop:      make(chan struct{}),
+	}
+	if watch.recursive {
+		err = watch.addTree(path)
+	} else {
+		err = watcher.Add(path)
+	}
+	if err != nil {
+		watcher.Close()
+		return "", fsError(err)
+	}
+
+	a.watchesMutex.Lock()
+	a.nextWatchID++
+	watch.id = "watch-" + strconv.Itoa(a.nextWatchID)
+	a.watches[watch.id] = watch
+	a.watchesMutex.Unlock()
+
+	go func() {
+		watch.run()
+		a.watchesMutex.Lock()
+		delete(a.watches, watch.id)
+		a.watchesMutex.Unlock()
+	}()
+	return watch.id, nil
+}
+
+// addTree watches dir and all directories below it. fsnotify watches a single level.
+func (w *fileWatch) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// The root must be watchable, subdirectories may vanish or be unreadable
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return w.watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// run sends changes to the page in batches until the watch is stopped or the page disconnects.
+func (w *fileWatch) run() {
+	defer w.watcher.Close()
+
+	var batch []fsWatchEvent
+	seen := make(map[fsWatchEvent]bool)
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.client.done:
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.recursive && event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.addTree(event.Name)
+				}
+			}
+			op := watchOp(event.Op)
+			if op == "" || (w.filter != "" && !matchFilter(w.filter, event.Name)) {
+				continue
+			}
+			// The batch is sent a moment after its first change, so files that change
+			// all the time are still reported
+			if len(batch) == 0 {
+				timer.Reset(watchDebounce)
+			}
+			change := fsWatchEvent{Path: event.Name, Op: op}
+			if !seen[change] {
+				seen[change] = true
+				batch = append(batch, change)
+			}
+		case <-timer.C:
+			if err := w.client.writeJSON(rpcNotification{JSONRPC: "2.0", Method: "fs.watch", Params: fsWatchNotification{ID: w.id, Events: batch}}); err != nil {
+				slog.Error("❌ Error sending file changes", "watch", w.id, "error", err)
+				return
+			}
+			batch = nil
+			clear(seen)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("❌ File watch error", "watch", w.id, "error", err)
+		}
+	}
+}
+
+// watchOp names the change reported by fsnotify. Permission changes are not reported.
+func watchOp(op fsnotify.Op) string {
+	switch {
+	case op.Has(fsnotify.Create):
+		return "create"
+	case op.Has(fsnotify.Write):
+		return "write"
+	case op.Has(fsnotify.Remove):
+		return "remove"
+	case op.Has(fsnotify.Rename):
+		return "rename"
+	}
+	return ""
+}
+
+// matchFilter reports whether the file name of path matches the glob pattern filter.
+func matchFilter(filter string, path string) bool {
+	matched, _ := filepath.Match(filter, filepath.Base(path))
+	return matched
+}
@@ -0,0 +1,573 @@
+// Package gohta runs HTML applications in a Chrome app-mode window backed by a local Go server.
+package gohta
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const showLog = true
+
+//go:embed embed
+var embeddedFS embed.FS
+
+// Options configures an App.
+type Options struct {
+	// FS holds the app content, for example an embed.FS narrowed with fs.Sub.
+	// If FS is nil, the app is served from the Root directory on disk.
+	FS fs.FS
+	// Root is the directory on disk that holds the app content when FS is nil.
+	Root string
+	// Entry is the HTML file opened in the window, relative to the app root.
+	// If empty, index.html is opened.
+	Entry string
+	// Args are the application arguments returned by gohta.core.getArgs().
+	Args []string
+	// AllowedRoots are additional directories the /file/ handler may serve from.
+	AllowedRoots []string
+	// Clipboard backs gohta.clipboard. If nil, the system clipboard is detected.
+	Clipboard Clipboard
+	// SingleInstance forwards later launches of the same app ID to the running app,
+	// as does singleinstance="yes" in the gohta:application tag.
+	SingleInstance bool
+	// EnvAllowlist lists the environment variables pages can read with gohta.os.
+	// Entries ending in * match a prefix. If nil, a default list of common variables is used.
+	EnvAllowlist []string
+	// ID identifies the app, for example "com.example.notes". It names the directory
+	// that holds per-app data such as the settings store. Defaults to "gohta".
+	ID string
+	// Browser selects the browser by name ("chrome", "chromium", "edge" or "brave") or by
+	// executable path. If empty, the GOHTA_BROWSER environment variable is used, and
+	// otherwise the first installed browser is detected.
+	Browser string
+	// Launcher starts the browser window, overriding Browser. Tests can use it to
+	// substitute a fake browser.
+	Launcher BrowserLauncher
+	// GracePeriod is how long the app keeps running after its last page closes, so that
+	// reloads and navigation do not end it. Defaults to 5 seconds.
+	GracePeriod time.Duration
+	// Ephemeral uses a temporary Chrome profile that is deleted on exit instead of the
+	// persistent per-app profile, as does ephemeral="yes" in the gohta:application tag.
+	Ephemeral bool
+	// ProfileDir is the Chrome profile directory to use instead of the per-app one.
+	ProfileDir string
+	// Port is the port of the local server. If zero, a free port is chosen.
+	Port int
+	// Bind is the address the server listens on. Defaults to localhost. Other pages
+	// and machines still need the launch token to use the API.
+	Bind string
+	// NoBrowser serves the app without opening a browser window. The URL to open is printed.
+	NoBrowser bool
+	// RuntimeFile is written with the URL, port, token and PID of the running server
+	// so scripts can find it, and removed on exit.
+	RuntimeFile string
+	// Width and Height set the initial window size, overriding the gohta:application
+	// tag and the remembered geometry.
+	Width, Height int
+	// Dev enables development mode with live reload. It defaults to on in builds with
+	// the dev tag.
+	Dev bool
+}
+
+// App is a gohta application: a local HTTP server plus the Chrome window showing it.
+type App struct {
+	opts         Options
+	contentFS    fs.FS
+	staticServer http.Handler
+	mux          *http.ServeMux
+
+	apiMethods  map[string]APIFunc
+	apiBuiltins map[string]bool
+	apiMutex    sync.RWMutex
+
+	authToken        string
+	tokenCookieName  string
+	launchCodes      map[string]bool
+	launchCodesMutex sync.Mutex
+
+	allowedRoots      []string
+	allowedRootsMutex sync.RWMutex
+
+	appOptions ApplicationOptions
+
+	baseURL    string
+	launcher   BrowserLauncher
+	profileDir string
+	cdp        *cdpClient
+	cdpMutex   sync.Mutex
+
+	windowTargets map[string]string // window ID to DevTools target ID
+	nextWindowID  int
+	windowsMutex  sync.Mutex
+
+	windowState      savedWindowState
+	hasWindowState   bool
+	windowStateMutex sync.Mutex
+
+	tasks      map[string]*Task
+	nextTaskID int
+	tasksMutex sync.Mutex
+
+	watches      map[string]*fileWatch
+	nextWatchID  int
+	watchesMutex sync.Mutex
+
+	events    *eventHub
+	pages     *pageTracker
+	store     *Store
+	clipboard Clipboard
+
+	quitRequests  chan int
+	forceQuit     chan struct{}
+	forceQuitOnce sync.Once
+	forceQuitCode int
+	exitCode      int
+
+	startupHooks  []func(ctx context.Context) error
+	shutdownHooks []func(ctx context.Context)
+}
+
+// New creates an App from opts. Register API methods, routes and hooks before calling Run.
+func New(opts Options) (*App, error) {
+	a := &App{
+		opts:       opts,
+		mux:        http.NewServeMux(),
+		apiMethods: make(map[string]APIFunc),
+		events:     newEventHub(),
+		pages:      newPageTracker(),
+
+		quitRequests: make(chan int, 1),
+		forceQuit:    make(chan struct{}),
+
+		windowTargets: make(map[string]string),
+		watches:       make(map[string]*fileWatch),
+		tasks:         make(map[string]*Task),
+	}
+	a.opts.ID = sanitizeID(opts.ID)
+	a.opts.Dev = opts.Dev || IsDev
+
+	if opts.FS != nil {
+		a.contentFS = opts.FS
+	} else {
+		if opts.Root == "" {
+			return nil, errors.New("either Options.FS or Options.Root must be set")
+		}
+		absRoot, err := filepath.Abs(opts.Root)
+		if err != nil {
+			return nil, fmt.Errorf("error getting absolute path for app root: %w", err)
+		}
+		a.opts.Root = absRoot
+		a.contentFS = os.DirFS(absRoot)
+	}
+	a.staticServer = http.FileServer(http.FS(a.contentFS))
+
+	if err := a.loadApplicationOptions(); err != nil {
+		return nil, err
+	}
+
+	if err := a.initStore(); err != nil {
+		return nil, err
+	}
+
+	a.initClipboard()
+
+	a.registerCoreAPI()
+	a.registerLifecycleAPI()
+	a.registerFSAPI()
+	a.registerFSWatchAPI()
+	a.registerShellAPI()
+	a.registerStoreAPI()
+	a.registerClipboardAPI()
+	a.registerOSAPI()
+	a.registerWindowAPI()
+	a.registerTasksAPI()
+	a.markBuiltinAPI()
+
+	// Register routes
+	a.mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		a.staticServer.ServeHTTP(w, r)
+	})
+	a.mux.HandleFunc("/", a.htmlHandler())
+	a.mux.HandleFunc("/api/", a.RequireToken(a.apiHandler))
+	a.mux.HandleFunc("/file/", a.RequireToken(a.fileHandler))
+	a.mux.HandleFunc("/ws/rpc", a.RequireToken(a.rpcHandler))
+
+	// Serve embedded files
+	embedDir, err := fs.Sub(embeddedFS, "embed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embed subdirectory: %w", err)
+	}
+	a.mux.Handle("/embed/", http.StripPrefix("/embed/", http.FileServer(http.FS(embedDir))))
+
+	return a, nil
+}
+
+// Handle registers an extra route on the app server. Routes are not guarded by the
+// launch token unless the handler is wrapped with RequireToken.
+func (a *App) Handle(pattern string, handler http.Handler) {
+	a.mux.Handle(pattern, handler)
+}
+
+// OnStartup registers a hook that runs after the server starts and before the window opens.
+// An error from a hook aborts Run.
+func (a *App) OnStartup(fn func(ctx context.Context) error) {
+	a.startupHooks = append(a.startupHooks, fn)
+}
+
+// OnShutdown registers a hook that runs when the app closes, while pages are still
+// connected and before the browser and the server stop. Hooks run in the order they
+// were registered.
+func (a *App) OnShutdown(fn func(ctx context.Context)) {
+	a.shutdownHooks = append(a.shutdownHooks, fn)
+}
+
+// loadApplicationOptions reads the gohta:application tag of the entry page and merges
+// it with Options. An id attribute in the tag takes precedence over Options.ID.
+func (a *App) loadApplicationOptions() error {
+	content, err := a.readFile(a.entryPath())
+	if err != nil {
+		return fmt.Errorf("error reading entry file: %w", err)
+	}
+	appOptions, warnings := parseApplicationTag(string(content))
+	for _, warning := range warnings {
+		slog.Warn("⚠️  gohta:application", "warning", warning)
+	}
+	if appOptions.ID != "" {
+		a.opts.ID = sanitizeID(appOptions.ID)
+	}
+	appOptions.SingleInstance = appOptions.SingleInstance || a.opts.SingleInstance
+	appOptions.Ephemeral = appOptions.Ephemeral || a.opts.Ephemeral
+	a.appOptions = appOptions
+	return nil
+}
+
+// entryPath returns the path of the entry HTML file relative to the app root.
+func (a *App) entryPath() string {
+	if a.opts.Entry == "" {
+		return "index.html"
+	}
+	return a.opts.Entry
+}
+
+// createListener creates a listener on bind and port. Port 0 picks an available port.
+func createListener(bind string, port int) (net.Listener, error) {
+	return net.Listen("tcp", net.JoinHostPort(bind, strconv.Itoa(port)))
+}
+
+// urlHost returns the host for URLs of a server listening on bind. Loopback and
+// wildcard addresses are reached through localhost.
+func urlHost(bind string) string {
+	ip := net.ParseIP(bind)
+	if bind == "" || ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		return "localhost"
+	}
+	return bind
+}
+
+// Run starts the server, opens the app window and blocks until the window is closed
+// or ctx is cancelled.
+func (a *App) Run(ctx context.Context) error {
+	slog.Debug("Development mode", "enabled", a.opts.Dev)
+
+	appOptions := a.appOptions
+	restored := false
+	if appOptions.RememberWindow {
+		if state, ok := a.loadWindowState(); ok {
+			slog.Info("💡 Restoring window", "width", state.Width, "height", state.Height, "left", state.Left, "top", state.Top)
+			appOptions.applyWindowState(state)
+			restored = true
+		}
+	}
+	if a.opts.Width > 0 && a.opts.Height > 0 {
+		appOptions.Width, appOptions.Height = a.opts.Width, a.opts.Height
+		if !restored {
+			appOptions.WindowState = ""
+		}
+	} else if !restored && appOptions.Width > 0 {
+		slog.Info("💡 Found gohta:application tag", "width", appOptions.Width, "height", appOptions.Height)
+	}
+
+	// In single-instance mode, hand this launch to a running instance if there is one
+	if appOptions.SingleInstance {
+		socketPath, err := a.instanceSocketPath()
+		if err != nil {
+			return fmt.Errorf("error finding the single-instance socket: %w", err)
+		}
+		instanceListener, err := a.claimInstance(socketPath)
+		if errors.Is(err, ErrAlreadyRunning) {
+			slog.Info("📨 Forwarded launch to the running instance")
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		defer instanceListener.Close()
+	}
+
+	// Restrict /file/ to the app directory and the configured allowed roots
+	if a.opts.Root != "" {
+		if err := a.AddAllowedRoot(a.opts.Root, ""); err != nil {
+			return fmt.Errorf("error resolving app directory: %w", err)
+		}
+	}
+	for _, root := range slices.Concat(a.opts.AllowedRoots, appOptions.AllowedRoots) {
+		if err := a.AddAllowedRoot(root, a.opts.Root); err != nil {
+			slog.Warn("⚠️  Ignoring allowed root", "root", root, "error", err)
+		}
+	}
+
+	// Chrome profile directory, kept per app ID unless the app is ephemeral
+	if !a.opts.NoBrowser {
+		profile, err := a.openProfile()
+		if err != nil {
+			return fmt.Errorf("error creating Chrome profile directory: %w", err)
+		}
+		defer profile.Close()
+		a.profileDir = profile.dir
+	}
+
+	// Initialize development mode if enabled
+	if a.opts.Dev && a.opts.Root != "" {
+		a.initDevMode(a.mux, a.opts.Root)
+	}
+
+	// Create listener on the configured or an available port
+	bind := ternary(a.opts.Bind != "", a.opts.Bind, "localhost")
+	listener, err := createListener(bind, a.opts.Port)
+	if err != nil {
+		return fmt.Errorf("error creating listener: %w", err)
+	}
+	defer listener.Close()
+
+	// Extract port number
+	addr := listener.Addr().(*net.TCPAddr)
+	port := addr.Port
+
+	a.baseURL = fmt.Sprintf("http://%s", net.JoinHostPort(urlHost(bind), strconv.Itoa(port)))
+
+	// Mint the per-launch token that guards the API and file endpoints
+	if err := a.initAuthToken(port); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:      ternary(showLog, loggingMiddleware(a.mux), http.Handler(a.mux)),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	// Start server in goroutine
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("🚀 Server running", "url", a.baseURL)
+
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for the server to start by probing the port
+	for i := 0; i < 100; i++ { // Limit retries to prevent infinite loop
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+			break // Server is ready
+		}
+		time.Sleep(20 * time.Millisecond)
+		if i == 99 {
+			return errors.New("failed to start server: could not connect after several retries")
+		}
+	}
+
+	for _, hook := range a.startupHooks {
+		if err := hook(ctx); err != nil {
+			a.shutdownServer(server)
+			return fmt.Errorf("startup hook failed: %w", err)
+		}
+	}
+
+	// runCtx ends when Run stops waiting for the app to close. It does not end with ctx,
+	// since pages may cancel the shutdown that ctx requests.
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+
+	// Open in Chrome app mode. Each launch URL carries its own single-use code.
+	url, err := a.launchURL(a.opts.Entry)
+	if err != nil {
+		a.shutdownServer(server)
+		return err
+	}
+	browserDone := make(chan error, 1)
+	if a.opts.RuntimeFile != "" {
+		runtimeURL, err := a.launchURL(a.opts.Entry)
+		if err == nil {
+			err = a.writeRuntimeFile(runtimeURL, port)
+		}
+		if err != nil {
+			a.shutdownServer(server)
+			return fmt.Errorf("error writing runtime file: %w", err)
+		}
+		defer os.Remove(a.opts.RuntimeFile)
+	}
+	var cmd *exec.Cmd
+	var cdp *cdpClient
+	var launchErr error
+	var launchTimedOut <-chan time.Time
+	if a.opts.NoBrowser {
+		slog.Info("🔗 Open the app in your browser. The server stops when the last page is closed.", "url", url)
+	} else if cmd, cdp, launchErr = a.launchBrowser(url, a.profileDir, appOptions.chromeArgs()); launchErr != nil {
+		slog.Warn("⚠️  Failed to open the app window. Open the URL in your browser.", "url", url, "error", launchErr, "timeout", launchTimeout)
+		// Keep serving for a while in case the URL is opened by hand
+		launchTimedOut = time.After(launchTimeout)
+	} else {
+		slog.Info("🌐 Browser opened in app mode. The server stops when the window is closed.")
+		go func() {
+			browserDone <- cmd.Wait()
+		}()
+		go func() {
+			if a.connectDevTools(runCtx, cdp) && appOptions.RememberWindow {
+				a.trackWindowState(runCtx)
+			}
+		}()
+	}
+	defer func() {
+		a.cdpMutex.Lock()
+		if a.cdp != nil {
+			a.cdp.Close()
+		}
+		a.cdpMutex.Unlock()
+	}()
+
+	// Wait for the pages to close, a quit, the context to end or the server to fail. The
+	// browser process is not a reliable signal: it may hand the window to another process and exit.
+	pagesIdle := a.pages.waitIdle(runCtx, a.gracePeriod())
+	shutdownRequested := ctx.Done()
+	var runErr error
+wait:
+	for {
+		select {
+		case <-pagesIdle:
+			slog.Info("👋 App window closed. Shutting down server...")
+			break wait
+		case err := <-browserDone:
+			browserDone = nil
+			if err != nil {
+				slog.Error("❌ Error waiting for browser process", "error", err)
+			}
+			if !a.pages.hasConnected() {
+				// Give a browser that was handed the URL time to open it
+				launchTimedOut = time.After(launchTimeout)
+			}
+		case <-launchTimedOut:
+			if !a.pages.hasConnected() {
+				slog.Info("👋 No app window opened. Shutting down server...")
+				if launchErr != nil {
+					runErr = fmt.Errorf("failed to open the app window: %w", launchErr)
+				}
+				break wait
+			}
+		case code := <-a.quitRequests:
+			slog.Info("👋 Quit requested", "code", code)
+			if a.confirmQuit(code) {
+				a.exitCode = code
+				break wait
+			}
+			slog.Warn("⚠️  Quit cancelled by a page")
+		case <-shutdownRequested:
+			slog.Info("🔌 Shutdown requested")
+			if a.confirmQuit(0) {
+				break wait
+			}
+			slog.Warn("⚠️  Shutdown cancelled by a page")
+			shutdownRequested = nil
+		case <-a.forceQuit:
+			break wait
+		case err := <-serverErr:
+			return fmt.Errorf("server failed: %w", err)
+		}
+	}
+	select {
+	case <-a.forceQuit:
+		// Also when it ended a wait for the pages to answer
+		slog.Info("👋 Forced quit", "code", a.forceQuitCode)
+		a.exitCode = a.forceQuitCode
+	default:
+	}
+	stopRun()
+
+	if appOptions.RememberWindow {
+		a.saveWindowState()
+	}
+
+	// Shutdown hooks run first, so that they can still send events to the pages. They
+	// get their own deadline since ctx may already be cancelled.
+	hookCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, hook := range a.shutdownHooks {
+		hook(hookCtx)
+	}
+
+	// Cancel the running tasks, which can still report how they ended
+	a.cancelTasks(5 * time.Second)
+
+	// Disconnect the pages and let the calls they started, such as store writes, finish
+	a.events.closeAll(5 * time.Second)
+
+	if cmd != nil {
+		slog.Info("👋 Closing browser...")
+		a.closeBrowser(cmd, browserDone)
+	}
+
+	// The deferred calls remove the runtime file and release or delete the profile
+	if err := a.shutdownServer(server); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// shutdownServer gracefully stops the HTTP server. Connections that are still busy after
+// 5 seconds are closed. Browsers preconnect connections they may never send a request
+// on, and Shutdown waits for those until they are 5 seconds old.
+func (a *App) shutdownServer(server *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("failed to shutdown server: %w", err)
+		}
+		slog.Debug("Closing connections that did not finish in time")
+		server.Close()
+	}
+
+	slog.Info("Server shutdown successfully")
+	return nil
+}
+
+// Logging middleware
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/app/heartbeat" {
+			// Heartbeats arrive every few seconds and would drown out other requests
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		slog.Debug("📥 Request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+		slog.Debug("✅ Request completed", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,293 @@
+package gohta
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLauncher records launches. It starts the test binary as a browser that answers
+// every DevTools call over the inherited pipes.
+type fakeLauncher struct {
+	launches chan []string
+}
+
+func (l *fakeLauncher) Name() string {
+	return "fake browser"
+}
+
+func (l *fakeLauncher) Launch(args []string, files []*os.File) (*exec.Cmd, error) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperBrowserProcess$")
+	cmd.Env = append(os.Environ(), "GOHTA_HELPER_BROWSER=1")
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	l.launches <- args
+	return cmd, nil
+}
+
+// TestHelperBrowserProcess is the browser started by fakeLauncher. It reads DevTools
+// messages from file descriptor 3 and answers them on 4 until Browser.close.
+func TestHelperBrowserProcess(t *testing.T) {
+	if os.Getenv("GOHTA_HELPER_BROWSER") != "1" {
+		return
+	}
+	commands := bufio.NewReader(os.NewFile(3, "commands"))
+	responses := os.NewFile(4, "responses")
+	for {
+		message, err := commands.ReadBytes(0)
+		if err != nil {
+			os.Exit(0)
+		}
+		var request struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+		}
+		json.Unmarshal(message[:len(message)-1], &request)
+		response, _ := json.Marshal(map[string]any{"id": request.ID, "result": map[string]any{}})
+		responses.Write(append(response, 0))
+		if request.Method == "Browser.close" {
+			os.Exit(0)
+		}
+	}
+}
+
+// newTestApp creates an app serving a directory with an index.html, with its data
+// directories inside the test's temporary directory.
+func newTestApp(t *testing.T, opts Options) *App {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	root := filepath.Join(dir, "app")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("<html><body>Test</body></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.Root = root
+	opts.ID = "gohta-test"
+	if opts.ProfileDir == "" {
+		opts.ProfileDir = filepath.Join(dir, "profile")
+	}
+	if opts.RuntimeFile == "" {
+		opts.RuntimeFile = filepath.Join(dir, "runtime.json")
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// startApp runs the app until the test ends and returns a channel with the result of Run.
+func startApp(t *testing.T, a *App) (stop context.CancelFunc, done <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		result <- a.Run(ctx)
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-finished
+	})
+	return cancel, result
+}
+
+// waitForRuntimeFile returns the runtime file once Run has written it.
+func waitForRuntimeFile(t *testing.T, path string) runtimeInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			var info runtimeInfo
+			if err := json.Unmarshal(content, &info); err != nil {
+				t.Fatal(err)
+			}
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runtime file was not written: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func waitForRun(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("Run did not return after the shutdown request")
+	}
+}
+
+// newTestClient returns a client with its own transport that returns redirects
+// instead of following them. Its idle connections are closed when the test ends.
+func newTestClient(t *testing.T) (*http.Client, *http.Transport) {
+	transport := &http.Transport{}
+	t.Cleanup(transport.CloseIdleConnections)
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return client, transport
+}
+
+func TestRunWithoutBrowser(t *testing.T) {
+	launcher := &fakeLauncher{launches: make(chan []string, 1)}
+	a := newTestApp(t, Options{Launcher: launcher, NoBrowser: true})
+	stop, done := startApp(t, a)
+
+	info := waitForRuntimeFile(t, a.opts.RuntimeFile)
+	if info.PID != os.Getpid() || info.Token == "" || !strings.HasPrefix(info.URL, info.BaseURL+"/") {
+		t.Errorf("unexpected runtime file %+v", info)
+	}
+
+	client, transport := newTestClient(t)
+	response, err := client.Get(info.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound {
+		t.Errorf("launch URL returned %d, want %d", response.StatusCode, http.StatusFound)
+	}
+
+	request, _ := http.NewRequest("POST", info.BaseURL+"/api/core/getArgs", nil)
+	request.Header.Set(tokenHeader, info.Token)
+	request.Header.Set("Content-Type", "application/json")
+	response, err = client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("API call with the token returned %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	transport.CloseIdleConnections()
+	stop()
+	waitForRun(t, done)
+	if _, err := os.Stat(a.opts.RuntimeFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("runtime file was not removed: %v", err)
+	}
+	select {
+	case args := <-launcher.launches:
+		t.Errorf("browser launched with %v in NoBrowser mode", args)
+	default:
+	}
+}
+
+func TestRunLaunchesBrowser(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake browser reads DevTools messages from file descriptors 3 and 4")
+	}
+	launcher := &fakeLauncher{launches: make(chan []string, 1)}
+	a := newTestApp(t, Options{Launcher: launcher})
+	stop, done := startApp(t, a)
+
+	var args []string
+	select {
+	case args = <-launcher.launches:
+	case <-time.After(10 * time.Second):
+		t.Fatal("browser was not launched")
+	}
+	if !slices.Contains(args, "--remote-debugging-pipe") || !slices.Contains(args, "--user-data-dir="+a.opts.ProfileDir) {
+		t.Errorf("launch arguments %v lack the DevTools pipe or the profile", args)
+	}
+	var appURL string
+	for _, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--app="); ok {
+			appURL = value
+		}
+	}
+	parsed, err := url.Parse(appURL)
+	if err != nil || parsed.Query().Get(launchQueryParam) == "" {
+		t.Errorf("app URL %q has no launch code", appURL)
+	}
+	if strings.Contains(appURL, a.authToken) {
+		t.Errorf("app URL %q contains the token", appURL)
+	}
+
+	// Window control becomes available over the pipes
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := a.devTools(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("DevTools did not connect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stop()
+	waitForRun(t, done)
+}
+
+func TestRunShutsDownWithUnusedConnection(t *testing.T) {
+	a := newTestApp(t, Options{NoBrowser: true})
+	stop, done := startApp(t, a)
+	info := waitForRuntimeFile(t, a.opts.RuntimeFile)
+
+	// Like a browser preconnect, which never sends a request
+	conn, err := net.Dial("tcp", strings.TrimPrefix(info.BaseURL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stop()
+	waitForRun(t, done)
+}
+
+// failingLauncher is a browser that cannot be started.
+type failingLauncher struct{}
+
+func (failingLauncher) Name() string {
+	return "missing browser"
+}
+
+func (failingLauncher) Launch(args []string, files []*os.File) (*exec.Cmd, error) {
+	return nil, errors.New("browser not installed")
+}
+
+func TestRunFailsWhenBrowserDoesNotStart(t *testing.T) {
+	defer func(timeout time.Duration) { launchTimeout = timeout }(launchTimeout)
+	launchTimeout = 200 * time.Millisecond
+
+	a := newTestApp(t, Options{Launcher: failingLauncher{}})
+	_, done := startApp(t, a)
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "browser not installed") {
+			t.Fatalf("Run returned %v, want the launch error", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run kept waiting after the browser failed to start")
+	}
+}
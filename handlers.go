@@ -1,12 +1,13 @@
-package main
+package gohta
 
 import (
 	"encoding/base64"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -27,7 +28,7 @@ func addScriptNode(n *html.Node, src string, isDefer bool) {
 }
 
 // htmlHandler serves static files and processes HTML files for script injection.
-func htmlHandler() http.HandlerFunc {
+func (a *App) htmlHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Handle routes outside of /app
 		if !strings.HasPrefix(r.URL.Path, "/app") {
@@ -39,6 +40,11 @@ func htmlHandler() http.HandlerFunc {
 			return
 		}
 
+		// Exchange the launch token for a cookie before serving any content
+		if a.handleTokenBootstrap(w, r) {
+			return
+		}
+
 		// Get the relative path of the requested file
 		relativePath := strings.TrimPrefix(r.URL.Path, "/app")
 		relativePath = strings.TrimPrefix(relativePath, "/")
@@ -48,7 +54,7 @@ func htmlHandler() http.HandlerFunc {
 			safePath = "."
 		}
 		// Check if the path is a directory and handle redirects/index.html
-		file, err := contentFS.Open(safePath)
+		file, err := a.contentFS.Open(safePath)
 		if err == nil {
 			info, err := file.Stat()
 			if err == nil && info.IsDir() {
@@ -57,32 +63,33 @@ func htmlHandler() http.HandlerFunc {
 					file.Close()
 					return
 				}
-				relativePath = filepath.Join(relativePath, "index.html")
+				relativePath = path.Join(relativePath, "index.html")
 			}
 			file.Close()
 		}
 
 		// If it's an HTML file, process it
 		if strings.HasSuffix(strings.ToLower(relativePath), ".html") {
-			content, err := readFile(relativePath)
+			content, err := a.readFile(relativePath)
 			if err != nil {
 				http.NotFound(w, r)
-				log.Printf("File not found: %s", relativePath)
+				slog.Warn("⚠️  File not found", "path", relativePath)
 				return
 			}
 
 			doc, err := html.Parse(strings.NewReader(string(content)))
 			if err != nil {
 				http.Error(w, "Could not parse HTML", http.StatusInternalServerError)
-				log.Printf("Error parsing HTML from %s: %v", relativePath, err)
+				slog.Error("❌ Error parsing HTML", "path", relativePath, "error", err)
 				return
 			}
 
 			var findHeadAndInject func(*html.Node)
 			findHeadAndInject = func(n *html.Node) {
 				if n.Type == html.ElementNode && n.Data == "head" {
+					a.appOptions.addHeadDefaults(n)
 					addScriptNode(n, "/embed/gohta.js", false)
-					if IsDev {
+					if a.opts.Dev {
 						addScriptNode(n, "/embed/development.js", true)
 					}
 					return
@@ -93,7 +100,7 @@ func htmlHandler() http.HandlerFunc {
 			}
 			findHeadAndInject(doc)
 
-			processImageTags(doc)
+			a.processImageTags(doc)
 
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			html.Render(w, doc)
@@ -102,35 +109,37 @@ func htmlHandler() http.HandlerFunc {
 
 		// For everything else, serve it as a static file
 		r.URL.Path = relativePath // Temporarily rewrite the path for the static server
-		staticServer.ServeHTTP(w, r)
+		a.staticServer.ServeHTTP(w, r)
 	}
 }
 
-func readFile(path string) ([]byte, error) {
-	contentPath := filepath.Join(rootDir, path)
-	if staticMode {		
-		contentPath = strings.ReplaceAll(contentPath, "\\", "/")
-		return staticFS.ReadFile(contentPath)
-	}
-	return os.ReadFile(contentPath)
+// readFile reads a file relative to the app root.
+func (a *App) readFile(name string) ([]byte, error) {
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	return fs.ReadFile(a.contentFS, name)
 }
 
 // processImageTags traverses HTML nodes and processes src attributes of img tags.
 // file:// paths are changed to URLs served by the server,
 // and relative path images are converted to Base64 data URIs and embedded in HTML.
-func processImageTags(n *html.Node) {
+func (a *App) processImageTags(n *html.Node) {
 	if n.Type == html.ElementNode && n.Data == "img" {
 		for i, attr := range n.Attr {
 			if attr.Key == "src" {
 				src := attr.Val
 				// Handle local file paths starting with file:// protocol
 				if strings.HasPrefix(src, "file://") {
-					n.Attr[i].Val = convertFileSrc(src)
+					newSrc, err := a.convertFileSrc(src)
+					if err != nil {
+						slog.Warn("⚠️  Not converting image outside allowed roots", "src", src, "error", err)
+						break
+					}
+					n.Attr[i].Val = newSrc
 				} else if !strings.HasPrefix(src, "data:") && !strings.HasPrefix(src, "http") {
 					// Embed relative path images by encoding them as Base64
-					imageData, err := readFile(src)
+					imageData, err := a.readFile(src)
 					if err != nil {
-						log.Printf("⚠️  Could not read image file for embedding %s: %v", src, err)
+						slog.Warn("⚠️  Could not read image file for embedding", "src", src, "error", err)
 						continue // Skip to next attribute if file cannot be read
 					}
 
@@ -146,13 +155,13 @@ func processImageTags(n *html.Node) {
 	}
 
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		processImageTags(c)
+		a.processImageTags(c)
 	}
 }
 
 // fileHandler serves files from the local file system through URLs with /file/ prefix.
 // Example: /file/C:/Users/user/image.png
-func fileHandler(w http.ResponseWriter, r *http.Request) {
+func (a *App) fileHandler(w http.ResponseWriter, r *http.Request) {
 	// Remove /file/ prefix from URL path to get actual file path
 	filePath := strings.TrimPrefix(r.URL.Path, "/file/")
 
@@ -160,11 +169,18 @@ func fileHandler(w http.ResponseWriter, r *http.Request) {
 	decodedPath, err := url.PathUnescape(filePath)
 	if err != nil {
 		http.Error(w, "Invalid file path", http.StatusBadRequest)
-		log.Printf("❌ Error decoding file path: %v", err)
+		slog.Error("❌ Error decoding file path", "error", err)
+		return
+	}
+
+	// Only serve files inside the allowed roots, after resolving symlinks
+	allowedPath, err := a.checkAllowedPath(localPathFromURL(decodedPath))
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		slog.Warn("⚠️  Refusing to serve file", "path", decodedPath, "error", err)
 		return
 	}
 
 	// http.ServeFile sanitizes paths for security and finds and serves files from the file system.
-	// Care must be taken with security when serving absolute paths.
-	http.ServeFile(w, r, decodedPath)
+	http.ServeFile(w, r, allowedPath)
 }
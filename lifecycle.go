@@ -0,0 +1,298 @@
+package gohta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// pageTimeout is how long a page may go without a heartbeat before it is considered
+	// gone. gohta.js sends one every 2 seconds.
+	pageTimeout = 10 * time.Second
+	// defaultGracePeriod is used when Options.GracePeriod is zero.
+	defaultGracePeriod = 5 * time.Second
+	// browserExitTimeout is how long Chrome may take to exit after it is asked to close.
+	browserExitTimeout = 5 * time.Second
+)
+
+// launchTimeout is how long to wait for the first page after the browser process exits
+// or fails to start. Launchers such as macOS open hand the URL over to another process
+// and exit at once. Tests shorten it.
+var launchTimeout = 30 * time.Second
+
+// beforeQuitTimeout is how long pages may take to answer app.beforeQuit. Tests shorten it.
+var beforeQuitTimeout = 15 * time.Second
+
+// pageState is the last heartbeat of a page.
+type pageState struct {
+	window   windowInfo
+	lastSeen time.Time
+}
+
+// pageTracker keeps track of the open pages from their heartbeats. The app shuts down
+// when the last page has been gone for the grace period.
+type pageTracker struct {
+	pages     map[string]pageState
+	connected bool      // a page has connected since launch
+	lastLeft  time.Time // when the number of pages dropped to zero
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+func newPageTracker() *pageTracker {
+	return &pageTracker{pages: make(map[string]pageState), now: time.Now}
+}
+
+// heartbeat records that the page is alive and showing in window.
+func (t *pageTracker) heartbeat(pageID string, window windowInfo) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.pages[pageID]; !ok {
+		slog.Info("📄 Page connected", "page", pageID, "window", window.ID)
+	}
+	t.pages[pageID] = pageState{window: window, lastSeen: t.now()}
+	t.connected = true
+}
+
+// goodbye removes a page that is being closed or navigated away from.
+func (t *pageTracker) goodbye(pageID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.pages[pageID]; ok {
+		slog.Info("📄 Page closed", "page", pageID)
+		delete(t.pages, pageID)
+		if len(t.pages) == 0 {
+			t.lastLeft = t.now()
+		}
+	}
+}
+
+// count returns the number of open pages, dropping those that stopped sending heartbeats.
+func (t *pageTracker) count() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := t.now()
+	for pageID, page := range t.pages {
+		if now.Sub(page.lastSeen) > pageTimeout {
+			slog.Warn("📄 Page stopped responding", "page", pageID)
+			delete(t.pages, pageID)
+			if len(t.pages) == 0 {
+				// The page went away around its last heartbeat
+				t.lastLeft = page.lastSeen
+			}
+		}
+	}
+	return len(t.pages)
+}
+
+// windows returns the windows with an open page, the main window first.
+func (t *pageTracker) windows() []windowInfo {
+	t.count()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	byID := make(map[string]pageState)
+	for _, page := range t.pages {
+		// While a window navigates, the new page is the one that reported last
+		if current, ok := byID[page.window.ID]; !ok || page.lastSeen.After(current.lastSeen) {
+			byID[page.window.ID] = page
+		}
+	}
+	windows := make([]windowInfo, 0, len(byID))
+	for _, page := range byID {
+		windows = append(windows, page.window)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		if (windows[i].ID == mainWindowID) != (windows[j].ID == mainWindowID) {
+			return windows[i].ID == mainWindowID
+		}
+		return windows[i].ID < windows[j].ID
+	})
+	return windows
+}
+
+// hasWindow reports whether a page is open in the window.
+func (t *pageTracker) hasWindow(windowID string) bool {
+	for _, window := range t.windows() {
+		if window.ID == windowID {
+			return true
+		}
+	}
+	return false
+}
+
+// idleFor reports whether pages have connected and none has been open for at least grace.
+func (t *pageTracker) idleFor(grace time.Duration) bool {
+	if t.count() > 0 {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.connected && t.now().Sub(t.lastLeft) >= grace
+}
+
+// hasConnected reports whether any page has connected since launch.
+func (t *pageTracker) hasConnected() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.connected
+}
+
+// waitIdle returns a channel that is closed once the last page has been gone for grace.
+func (t *pageTracker) waitIdle(ctx context.Context, grace time.Duration) <-chan struct{} {
+	idle := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if t.idleFor(grace) {
+					close(idle)
+					return
+				}
+			}
+		}
+	}()
+	return idle
+}
+
+// gracePeriod returns how long to wait after the last page closes before shutting down.
+func (a *App) gracePeriod() time.Duration {
+	if a.opts.GracePeriod > 0 {
+		return a.opts.GracePeriod
+	}
+	return defaultGracePeriod
+}
+
+// Quit asks the app to close with exit code code. Pages are sent app.beforeQuit first
+// and any of them can cancel the quit. If the pages agree, Run shuts the app down and
+// returns.
+func (a *App) Quit(code int) {
+	select {
+	case a.quitRequests <- code:
+	default:
+		// A quit is already pending
+	}
+}
+
+// ForceQuit closes the app with exit code code without asking the pages. Only the
+// first call has an effect.
+func (a *App) ForceQuit(code int) {
+	a.forceQuitOnce.Do(func() {
+		a.forceQuitCode = code
+		close(a.forceQuit)
+	})
+}
+
+// ExitCode returns the exit code passed to Quit or ForceQuit once Run has returned, or
+// 0 if the app was closed another way.
+func (a *App) ExitCode() int {
+	return a.exitCode
+}
+
+// confirmQuit sends app.beforeQuit to the pages and reports whether none of them
+// cancelled the quit. Pages that do not answer in time or disconnect do not prevent
+// it, and ForceQuit ends the wait.
+func (a *App) confirmQuit(code int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), beforeQuitTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-a.forceQuit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	clients := a.events.connected("")
+	answers := make(chan bool, len(clients))
+	for _, client := range clients {
+		go func() {
+			allow := true
+			if err := client.call(ctx, "app.beforeQuit", map[string]int{"code": code}, &allow); err != nil {
+				allow = true
+			}
+			answers <- allow
+		}()
+	}
+	allowed := true
+	for range clients {
+		allowed = <-answers && allowed
+	}
+	return allowed
+}
+
+// closeBrowser closes Chrome through DevTools, which lets it save the profile and
+// close all app windows, and waits for the process to exit. The process is killed if
+// DevTools is not connected or Chrome does not exit in time. done receives the result
+// of cmd.Wait and is nil if the process has already exited.
+func (a *App) closeBrowser(cmd *exec.Cmd, done <-chan error) {
+	closed := false
+	if cdp, err := a.devTools(); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := cdp.Call(ctx, "Browser.close", nil, nil)
+		cancel()
+		// Chrome may close the connection before it responds
+		closed = err == nil || errors.Is(err, errCDPClosed)
+	}
+	if cmd == nil || cmd.Process == nil || done == nil {
+		return
+	}
+	if closed {
+		select {
+		case <-done:
+			return
+		case <-time.After(browserExitTimeout):
+			slog.Warn("⚠️  Chrome did not exit in time")
+		}
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		slog.Error("❌ Failed to kill Chrome process", "error", err)
+		return
+	}
+	// The profile may be removed once the process is gone
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+}
+
+// registerLifecycleAPI registers the app.heartbeat, app.goodbye and app.quit methods used by gohta.js.
+func (a *App) registerLifecycleAPI() {
+	type pageRequest struct {
+		PageID   string `json:"pageId"`
+		WindowID string `json:"windowId"`
+		Path     string `json:"path"`
+		Title    string `json:"title"`
+	}
+	Register(a, "app.heartbeat", func(ctx context.Context, req pageRequest) (bool, error) {
+		if req.PageID == "" {
+			return false, &APIError{Status: http.StatusBadRequest, Message: "pageId is required"}
+		}
+		window := windowInfo{ID: ternary(req.WindowID != "", req.WindowID, mainWindowID), Path: req.Path, Title: req.Title}
+		a.pages.heartbeat(req.PageID, window)
+		return true, nil
+	})
+	Register(a, "app.goodbye", func(ctx context.Context, req pageRequest) (bool, error) {
+		a.pages.goodbye(req.PageID)
+		return true, nil
+	})
+	Register(a, "app.quit", func(ctx context.Context, req struct {
+		Code int `json:"code"`
+	}) (bool, error) {
+		if req.Code < 0 || req.Code > 255 {
+			return false, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("exit code must be between 0 and 255, got %d", req.Code)}
+		}
+		a.Quit(req.Code)
+		return true, nil
+	})
+}
@@ -0,0 +1,198 @@
+package gohta
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connectTestPage connects a page to the running app and returns the calls the app
+// makes to it. The page answers nothing by itself.
+func connectTestPage(t *testing.T, a *App) (*websocket.Conn, <-chan rpcRequest) {
+	t.Helper()
+	info := waitForRuntimeFile(t, a.opts.RuntimeFile)
+	header := http.Header{}
+	header.Set(tokenHeader, info.Token)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(info.BaseURL, "http")+"/ws/rpc", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	calls := make(chan rpcRequest, 10)
+	go func() {
+		for {
+			var call rpcRequest
+			if err := conn.ReadJSON(&call); err != nil {
+				return
+			}
+			calls <- call
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.events.connected("")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("page did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return conn, calls
+}
+
+// waitForBeforeQuit returns the next app.beforeQuit call made to the page.
+func waitForBeforeQuit(t *testing.T, calls <-chan rpcRequest) rpcRequest {
+	t.Helper()
+	for {
+		select {
+		case call := <-calls:
+			if call.Method == "app.beforeQuit" {
+				return call
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("page was not asked before quitting")
+		}
+	}
+}
+
+func answerBeforeQuit(t *testing.T, conn *websocket.Conn, call rpcRequest, allow bool) {
+	t.Helper()
+	if err := conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": call.ID, "result": allow}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuitCancelledByPage(t *testing.T) {
+	a := newTestApp(t, Options{NoBrowser: true})
+	_, done := startApp(t, a)
+	conn, calls := connectTestPage(t, a)
+
+	a.Quit(3)
+	answerBeforeQuit(t, conn, waitForBeforeQuit(t, calls), false)
+	select {
+	case err := <-done:
+		t.Fatalf("Run returned %v after the page cancelled the quit", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	a.Quit(4)
+	answerBeforeQuit(t, conn, waitForBeforeQuit(t, calls), true)
+	waitForRun(t, done)
+	if code := a.ExitCode(); code != 4 {
+		t.Errorf("exit code %d, want 4", code)
+	}
+}
+
+func TestQuitWhenPageDoesNotAnswer(t *testing.T) {
+	defer func(timeout time.Duration) { beforeQuitTimeout = timeout }(beforeQuitTimeout)
+	beforeQuitTimeout = 200 * time.Millisecond
+
+	a := newTestApp(t, Options{NoBrowser: true})
+	_, done := startApp(t, a)
+	_, calls := connectTestPage(t, a)
+
+	a.Quit(5)
+	waitForBeforeQuit(t, calls)
+	waitForRun(t, done)
+	if code := a.ExitCode(); code != 5 {
+		t.Errorf("exit code %d, want 5", code)
+	}
+}
+
+func TestForceQuitEndsBeforeQuit(t *testing.T) {
+	a := newTestApp(t, Options{NoBrowser: true})
+	_, done := startApp(t, a)
+	_, calls := connectTestPage(t, a)
+
+	a.Quit(5)
+	waitForBeforeQuit(t, calls)
+	a.ForceQuit(6)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(beforeQuitTimeout / 2):
+		t.Fatal("Run kept waiting for the page after ForceQuit")
+	}
+	if code := a.ExitCode(); code != 6 {
+		t.Errorf("exit code %d, want 6", code)
+	}
+}
+
+// newTestPageTracker returns a tracker whose clock only moves when advance is called.
+func newTestPageTracker() (tracker *pageTracker, advance func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker = newPageTracker()
+	tracker.now = func() time.Time { return now }
+	return tracker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestPageTrackerGoodbye(t *testing.T) {
+	tracker, advance := newTestPageTracker()
+	grace := 5 * time.Second
+	if tracker.idleFor(0) {
+		t.Error("idle before any page connected")
+	}
+
+	tracker.heartbeat("a", windowInfo{ID: mainWindowID})
+	tracker.heartbeat("b", windowInfo{ID: "settings"})
+	tracker.goodbye("a")
+	if count := tracker.count(); count != 1 {
+		t.Fatalf("count %d after one of two pages left, want 1", count)
+	}
+	tracker.goodbye("b")
+	if count := tracker.count(); count != 0 {
+		t.Fatalf("count %d after both pages left, want 0", count)
+	}
+
+	advance(grace - time.Second)
+	if tracker.idleFor(grace) {
+		t.Error("idle before the grace period ended")
+	}
+	advance(time.Second)
+	if !tracker.idleFor(grace) {
+		t.Error("not idle after the grace period")
+	}
+
+	// A page that connects again ends the idle state
+	tracker.heartbeat("c", windowInfo{ID: mainWindowID})
+	if tracker.idleFor(grace) {
+		t.Error("idle while a page is open")
+	}
+}
+
+func TestPageTrackerEvictsSilentPages(t *testing.T) {
+	tracker, advance := newTestPageTracker()
+	grace := 15 * time.Second
+
+	tracker.heartbeat("a", windowInfo{ID: mainWindowID})
+	tracker.heartbeat("b", windowInfo{ID: mainWindowID})
+	for range 3 {
+		advance(pageTimeout / 2)
+		tracker.heartbeat("b", windowInfo{ID: mainWindowID})
+	}
+	if count := tracker.count(); count != 1 {
+		t.Fatalf("count %d, want only the page that kept sending heartbeats", count)
+	}
+
+	advance(pageTimeout)
+	if tracker.idleFor(grace) {
+		t.Error("idle at the timeout of the last page")
+	}
+	advance(time.Second)
+	if count := tracker.count(); count != 0 {
+		t.Fatalf("count %d after the last page timed out, want 0", count)
+	}
+	// The grace period starts at the last heartbeat, not when the page was evicted
+	if tracker.idleFor(grace) {
+		t.Error("idle before the grace period ended")
+	}
+	advance(grace - pageTimeout - time.Second)
+	if !tracker.idleFor(grace) {
+		t.Error("not idle once the grace period after the last heartbeat ended")
+	}
+}
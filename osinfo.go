@@ -0,0 +1,178 @@
+package gohta
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultEnvAllowlist is used when Options.EnvAllowlist is nil.
+var defaultEnvAllowlist = []string{
+	"HOME", "USER", "USERNAME", "USERPROFILE", "LANG", "LANGUAGE", "LC_*", "SHELL", "TERM", "PATH",
+	"TMPDIR", "TEMP", "TMP", "APPDATA", "LOCALAPPDATA", "XDG_*",
+}
+
+// osInfo is returned by os.info.
+type osInfo struct {
+	Platform  string `json:"platform"`
+	Arch      string `json:"arch"`
+	Hostname  string `json:"hostname"`
+	Username  string `json:"username"`
+	HomeDir   string `json:"homeDir"`
+	TempDir   string `json:"tempDir"`
+	ConfigDir string `json:"configDir"`
+	CacheDir  string `json:"cacheDir"`
+}
+
+// registerOSAPI registers the os.* methods used by gohta.os in gohta.js.
+func (a *App) registerOSAPI() {
+	Register(a, "os.info", a.osInfo)
+	Register(a, "os.env", a.osEnv)
+	Register(a, "os.getEnv", a.osGetEnv)
+	Register(a, "os.folders", a.osFolders)
+}
+
+// osInfo returns information about the platform and the current user.
+func (a *App) osInfo(ctx context.Context, req struct{}) (osInfo, error) {
+	info := osInfo{
+		Platform: runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		TempDir:  os.TempDir(),
+	}
+	// Values that cannot be determined are left empty
+	info.Hostname, _ = os.Hostname()
+	info.HomeDir, _ = os.UserHomeDir()
+	info.ConfigDir, _ = os.UserConfigDir()
+	info.CacheDir, _ = os.UserCacheDir()
+	if current, err := user.Current(); err == nil {
+		info.Username = current.Username
+	}
+	return info, nil
+}
+
+// envAllowed reports whether name matches the env allowlist. Entries ending in * match prefixes.
+func (a *App) envAllowed(name string) bool {
+	allowlist := a.opts.EnvAllowlist
+	if allowlist == nil {
+		allowlist = defaultEnvAllowlist
+	}
+	if runtime.GOOS == "windows" {
+		// Environment variable names are case-insensitive on Windows
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range allowlist {
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// osEnv returns the allowed environment variables that are set.
+func (a *App) osEnv(ctx context.Context, req struct{}) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, ok := strings.Cut(entry, "=")
+		if ok && name != "" && a.envAllowed(name) {
+			env[name] = value
+		}
+	}
+	return env, nil
+}
+
+// osGetEnv returns one environment variable, or null if it is not set.
+func (a *App) osGetEnv(ctx context.Context, req struct {
+	Name string `json:"name"`
+}) (*string, error) {
+	if !a.envAllowed(req.Name) {
+		return nil, &APIError{Status: http.StatusForbidden, Message: "environment variable not allowed: " + req.Name}
+	}
+	value, ok := os.LookupEnv(req.Name)
+	if !ok {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+// osFolders returns well-known user folders. On Linux, they follow the XDG user
+// directories configuration in user-dirs.dirs.
+func (a *App) osFolders(ctx context.Context, req struct{}) (map[string]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	folders := map[string]string{
+		"home":      home,
+		"desktop":   filepath.Join(home, "Desktop"),
+		"documents": filepath.Join(home, "Documents"),
+		"downloads": filepath.Join(home, "Downloads"),
+		"music":     filepath.Join(home, "Music"),
+		"pictures":  filepath.Join(home, "Pictures"),
+		"videos":    filepath.Join(home, ternary(runtime.GOOS == "darwin", "Movies", "Videos")),
+	}
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		for key, dir := range xdgUserDirs(home) {
+			folders[key] = dir
+		}
+	}
+	return folders, nil
+}
+
+// xdgUserDirNames maps XDG user directory names to folder keys.
+var xdgUserDirNames = map[string]string{
+	"XDG_DESKTOP_DIR":     "desktop",
+	"XDG_DOCUMENTS_DIR":   "documents",
+	"XDG_DOWNLOAD_DIR":    "downloads",
+	"XDG_MUSIC_DIR":       "music",
+	"XDG_PICTURES_DIR":    "pictures",
+	"XDG_VIDEOS_DIR":      "videos",
+	"XDG_TEMPLATES_DIR":   "templates",
+	"XDG_PUBLICSHARE_DIR": "publicShare",
+}
+
+// xdgUserDirs reads user-dirs.dirs. Environment variables such as XDG_DOCUMENTS_DIR take precedence.
+func xdgUserDirs(home string) map[string]string {
+	dirs := make(map[string]string)
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if file, err := os.Open(filepath.Join(configHome, "user-dirs.dirs")); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// Lines look like XDG_DOCUMENTS_DIR="$HOME/Documents"
+			line := strings.TrimSpace(scanner.Text())
+			name, value, ok := strings.Cut(line, "=")
+			if !ok || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if key, ok := xdgUserDirNames[name]; ok {
+				value = strings.Trim(value, `"`)
+				value = strings.Replace(value, "$HOME", home, 1)
+				dirs[key] = filepath.Clean(value)
+			}
+		}
+	}
+
+	for name, key := range xdgUserDirNames {
+		if value := os.Getenv(name); value != "" {
+			dirs[key] = value
+		}
+	}
+	return dirs
+}
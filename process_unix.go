@@ -0,0 +1,28 @@
+//go:build !windows
+
+package gohta
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processAlive reports whether a process with the given PID is running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	// EPERM means the process exists but belongs to another user
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// inheritFiles passes files to cmd as file descriptors 3, 4 and so on.
+func inheritFiles(cmd *exec.Cmd, files []*os.File) {
+	cmd.ExtraFiles = files
+}
+
+// devToolsPipeArgs returns the Chrome arguments for DevTools over the inherited files.
+// Chrome reads from descriptor 3 and writes to descriptor 4.
+func devToolsPipeArgs(files []*os.File) []string {
+	return []string{"--remote-debugging-pipe"}
+}
@@ -0,0 +1,56 @@
+//go:build windows
+
+package gohta
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// processAlive reports whether a process with the given PID is running.
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// Access is denied for processes of other users, which are still running
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// inheritFiles lets cmd inherit the handles of files, which are named on its command line.
+func inheritFiles(cmd *exec.Cmd, files []*os.File) {
+	if len(files) == 0 {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	for _, file := range files {
+		handle := syscall.Handle(file.Fd())
+		syscall.SetHandleInformation(handle, syscall.HANDLE_FLAG_INHERIT, syscall.HANDLE_FLAG_INHERIT)
+		cmd.SysProcAttr.AdditionalInheritedHandles = append(cmd.SysProcAttr.AdditionalInheritedHandles, handle)
+	}
+}
+
+// devToolsPipeArgs returns the Chrome arguments for DevTools over the inherited files.
+// Windows has no descriptor numbers to inherit, so Chrome is given the handles to read
+// from and write to.
+func devToolsPipeArgs(files []*os.File) []string {
+	return []string{
+		"--remote-debugging-pipe",
+		fmt.Sprintf("--remote-debugging-io-pipes=%d,%d", files[0].Fd(), files[1].Fd()),
+	}
+}
@@ -0,0 +1,103 @@
+package gohta
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// chromeProfile is the Chrome user data directory of a launch.
+type chromeProfile struct {
+	dir       string
+	lockPath  string
+	ephemeral bool
+}
+
+// openProfile prepares the Chrome profile. Apps get a persistent profile under
+// UserCacheDir/gohta/<ID>/profile unless Options.ProfileDir is set, so IndexedDB, cookies and local storage survive
+// restarts. A lock file keeps two running instances of an app out of the same
+// profile; the later one falls back to a temporary profile.
+func (a *App) openProfile() (*chromeProfile, error) {
+	if a.appOptions.Ephemeral && a.opts.ProfileDir == "" {
+		return newEphemeralProfile()
+	}
+
+	dir := a.opts.ProfileDir
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			slog.Warn("⚠️  Could not find user cache directory, using a temporary Chrome profile", "error", err)
+			return newEphemeralProfile()
+		}
+		dir = filepath.Join(cacheDir, "gohta", a.opts.ID, "profile")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	profile := &chromeProfile{dir: dir, lockPath: dir + ".lock"}
+	if err := os.MkdirAll(profile.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if err := lockProfile(profile.lockPath); err != nil {
+		slog.Warn("⚠️  Using a temporary Chrome profile", "reason", err)
+		return newEphemeralProfile()
+	}
+
+	slog.Info("🗂️ Using Chrome profile", "dir", profile.dir)
+	return profile, nil
+}
+
+// newEphemeralProfile creates a temporary profile that is deleted by Close.
+func newEphemeralProfile() (*chromeProfile, error) {
+	dir, err := os.MkdirTemp("", "gohta-chrome-profile-")
+	if err != nil {
+		return nil, err
+	}
+	return &chromeProfile{dir: dir, ephemeral: true}, nil
+}
+
+// Close releases the profile lock, or deletes an ephemeral profile.
+func (p *chromeProfile) Close() error {
+	if p.ephemeral {
+		return os.RemoveAll(p.dir)
+	}
+	return os.Remove(p.lockPath)
+}
+
+// lockProfile creates the lock file holding the PID of this process. A lock left by a
+// process that is no longer running is taken over.
+func lockProfile(lockPath string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+
+		content, err := os.ReadFile(lockPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err == nil && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("Chrome profile is in use by process %d", pid)
+		}
+		// Stale lock
+		if err := os.Remove(lockPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return errors.New("could not lock Chrome profile")
+}
@@ -1,13 +1,7 @@
 //go:build !dev
 
-package main
-
-import "net/http"
+package gohta
 
+// IsDev is true in builds with the dev tag, which turn on development mode by default.
+// Release builds can still enable it with Options.Dev.
 const IsDev = false
-
-// initDevMode is a stub function for release builds. It does nothing.
-func initDevMode(mux *http.ServeMux, htmlFileDir string) {
-	// This function is intentionally left empty.
-	// The actual implementation for development mode is in development.go.
-}
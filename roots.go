@@ -0,0 +1,111 @@
+package gohta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// errPathNotAllowed is returned for paths outside every allowed root directory.
+var errPathNotAllowed = errors.New("path is outside the allowed root directories")
+
+// AddAllowedRoot allows files below dir to be served. Relative paths are resolved against base.
+// Call it for directories the user picks while the app is running.
+func (a *App) AddAllowedRoot(dir string, base string) error {
+	if !filepath.IsAbs(dir) && base != "" {
+		dir = filepath.Join(base, dir)
+	}
+	resolved, err := resolvePath(dir)
+	if err != nil {
+		return err
+	}
+
+	a.allowedRootsMutex.Lock()
+	defer a.allowedRootsMutex.Unlock()
+	for _, root := range a.allowedRoots {
+		if root == resolved {
+			return nil
+		}
+	}
+	a.allowedRoots = append(a.allowedRoots, resolved)
+	return nil
+}
+
+// resolvePath returns the absolute path with symlinks resolved. Paths that do not exist yet
+// are resolved through their closest existing parent directory.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rest := ""
+	current := absPath
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return absPath, nil
+		}
+		rest = filepath.Join(filepath.Base(current), rest)
+		current = parent
+	}
+}
+
+// checkAllowedPath resolves path and verifies that it lies inside one of the allowed roots.
+// It returns the resolved path on success.
+func (a *App) checkAllowedPath(path string) (string, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	a.allowedRootsMutex.RLock()
+	defer a.allowedRootsMutex.RUnlock()
+	for _, root := range a.allowedRoots {
+		rel, err := filepath.Rel(root, resolved)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", errPathNotAllowed
+}
+
+// parseRootList splits a semicolon-separated list of directories.
+func parseRootList(value string) []string {
+	var roots []string
+	for _, root := range strings.Split(value, ";") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// localPathFromURL converts a file:// URL or the path part of a /file/ URL to a local path.
+func localPathFromURL(path string) string {
+	path = strings.TrimPrefix(path, "file://")
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir -> C:/dir
+		if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+		return filepath.FromSlash(path)
+	}
+	// The router collapses the double slash in /file//home/..., so restore the leading slash
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package gohta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAllowedPath(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	for _, d := range []string{filepath.Join(root, "sub"), filepath.Join(dir, "root-evil"), filepath.Join(dir, "outside")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(root, "sub", "file.txt"))
+	writeFile(t, filepath.Join(dir, "outside", "secret.txt"))
+	symlink(t, filepath.Join(dir, "outside"), filepath.Join(root, "escape"))
+	symlink(t, filepath.Join(root, "sub"), filepath.Join(root, "inside"))
+
+	a := &App{}
+	if err := a.AddAllowedRoot(root, ""); err != nil {
+		t.Fatal(err)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed bool
+		want    string
+	}{
+		{"root itself", root, true, resolvedRoot},
+		{"file in root", filepath.Join(root, "sub", "file.txt"), true, filepath.Join(resolvedRoot, "sub", "file.txt")},
+		{"traversal", filepath.Join(root, "sub", "..", "..", "outside", "secret.txt"), false, ""},
+		{"unclean traversal", root + "/sub/../../outside/secret.txt", false, ""},
+		{"symlink out of root", filepath.Join(root, "escape", "secret.txt"), false, ""},
+		{"symlink within root", filepath.Join(root, "inside", "file.txt"), true, filepath.Join(resolvedRoot, "sub", "file.txt")},
+		{"sibling with root name as prefix", filepath.Join(dir, "root-evil", "file.txt"), false, ""},
+		{"missing file in root", filepath.Join(root, "new", "deeper", "file.txt"), true, filepath.Join(resolvedRoot, "new", "deeper", "file.txt")},
+		{"missing file through symlink out of root", filepath.Join(root, "escape", "new.txt"), false, ""},
+		{"missing file outside root", filepath.Join(dir, "missing", "file.txt"), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := a.checkAllowedPath(tt.path)
+			if !tt.allowed {
+				if !errors.Is(err, errPathNotAllowed) {
+					t.Fatalf("got %q, %v, want errPathNotAllowed", resolved, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved != tt.want {
+				t.Errorf("resolved to %s, want %s", resolved, tt.want)
+			}
+		})
+	}
+}
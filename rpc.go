@@ -0,0 +1,221 @@
+package gohta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes. Errors returned as *APIError use rpcAPIError, with the
+// HTTP status in data.status.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+	rpcAPIError       = -32000
+)
+
+// rpcRequest is a JSON-RPC request or, without an ID, a notification. Pages also send
+// responses to calls from the server, which have a result or an error instead of a method.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcResult struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// rpcCall is a request from the server to a page.
+type rpcCall struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// rpcNotification is a message without an ID. The server sends events as notifications.
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// rpcClientKey is the context key of the connection an API call arrived on.
+type rpcClientKey struct{}
+
+// rpcClientFrom returns the page connection of an API call made over /ws/rpc. Calls
+// made over HTTP have none.
+func rpcClientFrom(ctx context.Context) (*eventClient, bool) {
+	client, ok := ctx.Value(rpcClientKey{}).(*eventClient)
+	return client, ok
+}
+
+// rpcHandler serves the API as JSON-RPC 2.0 over a WebSocket, which also carries
+// events to the page. Calls are handled concurrently and may complete in any order.
+func (a *App) rpcHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("❌ RPC WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxAPIRequestSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Pages pass the ID of their window to receive events sent to it
+	windowID := r.URL.Query().Get("window")
+	client := &eventClient{conn: conn, windowID: ternary(windowID != "", windowID, mainWindowID), done: ctx.Done()}
+	a.events.add(client)
+	ctx = context.WithValue(ctx, rpcClientKey{}, client)
+
+	var calls sync.WaitGroup
+	defer func() {
+		// Cancel the calls still running when the page disconnects, and wait for them so
+		// that a shutdown waiting for this connection sees their writes completed
+		cancel()
+		calls.Wait()
+		a.events.remove(client)
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		calls.Add(1)
+		go func() {
+			defer calls.Done()
+			a.handleRPC(ctx, client, data)
+		}()
+	}
+}
+
+// handleRPC runs one JSON-RPC message and writes the response unless it is a notification.
+func (a *App) handleRPC(ctx context.Context, client *eventClient, data []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		client.writeJSON(rpcErrorResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+		return
+	}
+	if req.Method == "" && req.ID != nil && (req.Result != nil || req.Error != nil) {
+		client.deliver(req)
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := ternary(req.ID != nil, req.ID, json.RawMessage("null"))
+		client.writeJSON(rpcErrorResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}})
+		return
+	}
+
+	params := req.Params
+	if bytes.Equal(bytes.TrimSpace(params), []byte("null")) {
+		params = nil
+	}
+	fn, ok := a.lookupAPI(req.Method)
+	if !ok {
+		if req.ID != nil {
+			client.writeJSON(rpcErrorResponse{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}})
+		}
+		return
+	}
+
+	result, err := fn(ctx, params)
+	switch {
+	case req.ID == nil:
+		// Notifications get no response, but internal errors are still logged
+		if err != nil {
+			a.rpcErrorFrom(req.Method, err)
+		}
+	case err != nil:
+		client.writeJSON(rpcErrorResponse{JSONRPC: "2.0", ID: req.ID, Error: a.rpcErrorFrom(req.Method, err)})
+	default:
+		client.writeJSON(rpcResult{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}
+}
+
+// rpcErrorFrom converts an error of an API method to a JSON-RPC error.
+func (a *App) rpcErrorFrom(method string, err error) *rpcError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &rpcError{Code: rpcAPIError, Message: apiErr.Message, Data: map[string]int{"status": apiErr.Status}}
+	}
+	slog.Error("❌ Error in API method", "method", method, "error", err)
+	return &rpcError{Code: rpcInternalError, Message: err.Error()}
+}
+
+// call sends a request to the page and waits for the response, which is decoded into result.
+func (c *eventClient) call(ctx context.Context, method string, params any, result any) error {
+	response := make(chan rpcRequest, 1)
+	c.callsMutex.Lock()
+	if c.calls == nil {
+		c.calls = make(map[int64]chan rpcRequest)
+	}
+	c.nextCallID++
+	id := c.nextCallID
+	c.calls[id] = response
+	c.callsMutex.Unlock()
+	defer func() {
+		c.callsMutex.Lock()
+		delete(c.calls, id)
+		c.callsMutex.Unlock()
+	}()
+
+	if err := c.writeJSON(rpcCall{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return errors.New("page disconnected")
+	case resp := <-response:
+		if resp.Error != nil {
+			return fmt.Errorf("%s failed in page: %s", method, resp.Error.Message)
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// deliver passes a response from the page to the call waiting for it.
+func (c *eventClient) deliver(resp rpcRequest) {
+	var id int64
+	if err := json.Unmarshal(resp.ID, &id); err != nil {
+		return
+	}
+	c.callsMutex.Lock()
+	response, ok := c.calls[id]
+	c.callsMutex.Unlock()
+	if ok {
+		select {
+		case response <- resp:
+		default:
+			// A second response to the same call is ignored
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rpcTestResponse is a response read by the test client.
+type rpcTestResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// dialTestRPC serves the methods of a over /ws/rpc and connects to it.
+func dialTestRPC(t *testing.T, a *App) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(a.rpcHandler))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readRPCResponse(t *testing.T, conn *websocket.Conn) rpcTestResponse {
+	t.Helper()
+	var response rpcTestResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return response
+}
+
+func sendRPC(t *testing.T, conn *websocket.Conn, message string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRPCApp() *App {
+	a := &App{apiMethods: make(map[string]APIFunc), events: newEventHub()}
+	Register(a, "test.echo", func(ctx context.Context, req map[string]any) (map[string]any, error) {
+		return req, nil
+	})
+	Register(a, "test.fail", func(ctx context.Context, req struct{}) (bool, error) {
+		return false, &APIError{Status: http.StatusTeapot, Message: "no coffee"}
+	})
+	return a
+}
+
+func TestRPCErrors(t *testing.T) {
+	conn := dialTestRPC(t, newTestRPCApp())
+
+	tests := []struct {
+		name    string
+		message string
+		id      string
+		code    int
+	}{
+		{"parse error", `{"jsonrpc": "2.0", "id": 1, "method": `, "null", rpcParseError},
+		{"unknown method", `{"jsonrpc": "2.0", "id": 2, "method": "test.missing"}`, "2", rpcMethodNotFound},
+		{"invalid request", `{"jsonrpc": "1.0", "id": 3, "method": "test.echo"}`, "3", rpcInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendRPC(t, conn, tt.message)
+			response := readRPCResponse(t, conn)
+			if string(response.ID) != tt.id || response.Error == nil || response.Error.Code != tt.code {
+				t.Fatalf("got id %s and error %+v, want id %s and code %d", response.ID, response.Error, tt.id, tt.code)
+			}
+		})
+	}
+}
+
+func TestRPCAPIError(t *testing.T) {
+	conn := dialTestRPC(t, newTestRPCApp())
+
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "id": 1, "method": "test.fail"}`)
+	response := readRPCResponse(t, conn)
+	if response.Error == nil || response.Error.Code != rpcAPIError || response.Error.Message != "no coffee" {
+		t.Fatalf("got error %+v, want code %d", response.Error, rpcAPIError)
+	}
+	data, _ := response.Error.Data.(map[string]any)
+	if status, _ := data["status"].(float64); status != http.StatusTeapot {
+		t.Errorf("error data %v, want status %d", response.Error.Data, http.StatusTeapot)
+	}
+}
+
+func TestRPCNotificationGetsNoResponse(t *testing.T) {
+	a := newTestRPCApp()
+	notified := make(chan string, 1)
+	Register(a, "test.notify", func(ctx context.Context, req struct {
+		Text string `json:"text"`
+	}) (bool, error) {
+		notified <- req.Text
+		return true, nil
+	})
+	conn := dialTestRPC(t, a)
+
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "method": "test.notify", "params": {"text": "hello"}}`)
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "method": "test.missing"}`)
+	select {
+	case text := <-notified:
+		if text != "hello" {
+			t.Errorf("notification params %q, want hello", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification was not handled")
+	}
+
+	// The next message must answer this call, not the notifications
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "id": 7, "method": "test.echo", "params": {"n": 1}}`)
+	if response := readRPCResponse(t, conn); string(response.ID) != "7" {
+		t.Fatalf("got a response with id %s, want 7", response.ID)
+	}
+}
+
+func TestRPCConcurrentCalls(t *testing.T) {
+	a := newTestRPCApp()
+	release := make(chan struct{})
+	Register(a, "test.wait", func(ctx context.Context, req struct{}) (string, error) {
+		select {
+		case <-release:
+			return "released", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	})
+	Register(a, "test.release", func(ctx context.Context, req struct{}) (bool, error) {
+		close(release)
+		return true, nil
+	})
+	conn := dialTestRPC(t, a)
+
+	// The waiting call is still in flight while later calls complete
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "id": "slow", "method": "test.wait"}`)
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "id": 2, "method": "test.echo", "params": {"n": 2}}`)
+	if response := readRPCResponse(t, conn); string(response.ID) != "2" || string(response.Result) != `{"n":2}` {
+		t.Fatalf("got id %s with result %s, want id 2 with {\"n\":2}", response.ID, response.Result)
+	}
+
+	sendRPC(t, conn, `{"jsonrpc": "2.0", "id": 3, "method": "test.release"}`)
+	results := make(map[string]string)
+	for range 2 {
+		response := readRPCResponse(t, conn)
+		results[string(response.ID)] = string(response.Result)
+	}
+	if results[`"slow"`] != `"released"` || results["3"] != "true" {
+		t.Errorf("got results %v, want the slow call released and the release call answered", results)
+	}
+}
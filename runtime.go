@@ -0,0 +1,31 @@
+package gohta
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// runtimeInfo is written to Options.RuntimeFile.
+type runtimeInfo struct {
+	URL     string `json:"url"`
+	BaseURL string `json:"baseUrl"`
+	Port    int    `json:"port"`
+	Token   string `json:"token"`
+	PID     int    `json:"pid"`
+}
+
+// writeRuntimeFile writes the runtime file. Only the current user can read it since
+// it holds the launch token.
+func (a *App) writeRuntimeFile(launchURL string, port int) error {
+	content, err := json.MarshalIndent(runtimeInfo{
+		URL:     launchURL,
+		BaseURL: a.baseURL,
+		Port:    port,
+		Token:   a.authToken,
+		PID:     os.Getpid(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(a.opts.RuntimeFile, content)
+}
@@ -0,0 +1,249 @@
+package gohta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// shellWaitDelay is how long a process may leave its output open after it exits or is
+// killed, for example because a child process it started still holds it.
+const shellWaitDelay = 2 * time.Second
+
+// shellExecRequest describes a process started by shell.exec or the /ws/shell stream.
+type shellExecRequest struct {
+	Cmd   string            `json:"cmd"`
+	Args  []string          `json:"args"`
+	Cwd   string            `json:"cwd"`
+	Env   map[string]string `json:"env"`
+	Stdin string            `json:"stdin"`
+}
+
+// shellExecResult is the result of shell.exec.
+type shellExecResult struct {
+	Code   int    `json:"code"`
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// shellControlMessage is sent by the page to a streamed process: "stdin", "closeStdin" or "kill".
+type shellControlMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// shellStreamMessage is sent to the page: "stdout", "stderr", "exit" or "error".
+type shellStreamMessage struct {
+	Type    string `json:"type"`
+	Data    string `json:"data,omitempty"`
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// registerShellAPI registers shell.exec and the streaming /ws/shell endpoint.
+func (a *App) registerShellAPI() {
+	Register(a, "shell.exec", a.shellExec)
+	a.mux.HandleFunc("/ws/shell", a.RequireToken(a.shellStreamHandler))
+}
+
+// shellCommand builds the command for req. Relative working directories are resolved
+// against the app directory and env entries are added to the current environment.
+func (a *App) shellCommand(ctx context.Context, req shellExecRequest) (*exec.Cmd, error) {
+	if req.Cmd == "" {
+		return nil, &APIError{Status: http.StatusBadRequest, Message: "cmd is required"}
+	}
+
+	cmd := exec.CommandContext(ctx, req.Cmd, req.Args...)
+	cmd.WaitDelay = shellWaitDelay
+	if req.Cwd != "" {
+		cmd.Dir = req.Cwd
+		if !filepath.IsAbs(req.Cwd) && a.opts.Root != "" {
+			cmd.Dir = filepath.Join(a.opts.Root, req.Cwd)
+		}
+	}
+	if len(req.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range req.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	return cmd, nil
+}
+
+// exitCode returns the exit code of a finished command, or err if it did not run.
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The process succeeded, but something else kept its output open
+		return 0, nil
+	}
+	return 0, &APIError{Status: http.StatusBadRequest, Message: err.Error()}
+}
+
+// shellExec runs a process to completion and returns its exit code and output.
+func (a *App) shellExec(ctx context.Context, req shellExecRequest) (shellExecResult, error) {
+	cmd, err := a.shellCommand(ctx, req)
+	if err != nil {
+		return shellExecResult{}, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(req.Stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	slog.Info("🐚 Running command", "cmd", req.Cmd, "args", req.Args)
+	code, err := exitCode(cmd.Run())
+	if err != nil {
+		return shellExecResult{}, err
+	}
+	return shellExecResult{Code: code, Stdout: stdout.String(), Stderr: stderr.String()}, nil
+}
+
+// shellStreamHandler runs a process and streams its output over a WebSocket.
+// The first message from the page is a shellExecRequest, later messages are shellControlMessages.
+// The process is killed when the page disconnects.
+func (a *App) shellStreamHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("❌ Shell WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	var writeMutex sync.Mutex
+	send := func(msg shellStreamMessage) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		if err := conn.WriteJSON(msg); err != nil {
+			slog.Error("❌ Error sending shell output", "error", err)
+		}
+	}
+
+	var req shellExecRequest
+	if err := conn.ReadJSON(&req); err != nil {
+		slog.Error("❌ Error reading shell request", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd, err := a.shellCommand(ctx, req)
+	if err != nil {
+		send(shellStreamMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		send(shellStreamMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	// Output chunks are forwarded as they arrive. Wait returns once the output is
+	// closed, or shellWaitDelay after the process exits.
+	stdout := &shellOutputWriter{stream: "stdout", send: send}
+	stderr := &shellOutputWriter{stream: "stderr", send: send}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	slog.Info("🐚 Streaming command", "cmd", req.Cmd, "args", req.Args)
+	if err := cmd.Start(); err != nil {
+		send(shellStreamMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	// Handle stdin and kill requests until the page disconnects
+	go func() {
+		for {
+			var msg shellControlMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				cancel()
+				return
+			}
+			switch msg.Type {
+			case "stdin":
+				io.WriteString(stdin, msg.Data)
+			case "closeStdin":
+				stdin.Close()
+			case "kill":
+				cancel()
+			default:
+				slog.Warn("⚠️  Unknown shell message type", "type", msg.Type)
+			}
+		}
+	}()
+
+	// Input given with the request is written while kill requests are handled, since a
+	// process that does not read it would block the write
+	if req.Stdin != "" {
+		go func() {
+			io.WriteString(stdin, req.Stdin)
+			stdin.Close()
+		}()
+	}
+
+	code, err := exitCode(cmd.Wait())
+	stdout.flush()
+	stderr.flush()
+	if err != nil {
+		send(shellStreamMessage{Type: "error", Message: fmt.Sprintf("process failed: %v", err)})
+		return
+	}
+	send(shellStreamMessage{Type: "exit", Code: code})
+}
+
+// shellOutputWriter sends the output of a streamed process to the page. A UTF-8
+// character split between two writes is held back until it is complete.
+type shellOutputWriter struct {
+	stream  string
+	send    func(msg shellStreamMessage)
+	pending []byte
+}
+
+func (w *shellOutputWriter) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	complete := len(data) - incompleteUTF8Suffix(data)
+	if complete > 0 {
+		w.send(shellStreamMessage{Type: w.stream, Data: string(data[:complete])})
+	}
+	w.pending = append([]byte(nil), data[complete:]...)
+	return len(p), nil
+}
+
+// flush sends bytes still held back when the output ends.
+func (w *shellOutputWriter) flush() {
+	if len(w.pending) > 0 {
+		w.send(shellStreamMessage{Type: w.stream, Data: string(w.pending)})
+		w.pending = nil
+	}
+}
+
+// incompleteUTF8Suffix returns the length of a UTF-8 character at the end of p that
+// is missing bytes, or 0.
+func incompleteUTF8Suffix(p []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		if utf8.RuneStart(p[len(p)-i]) {
+			if utf8.FullRune(p[len(p)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package gohta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestShellOutputWriterKeepsCharactersWhole(t *testing.T) {
+	var chunks []string
+	w := &shellOutputWriter{stream: "stdout", send: func(msg shellStreamMessage) {
+		chunks = append(chunks, msg.Data)
+	}}
+
+	text := []byte("añ€😀")
+	for i := range text {
+		w.Write(text[i : i+1])
+	}
+	w.Write([]byte{0xe2, 0x82})
+	w.flush()
+
+	// The incomplete character at the end is sent as it is
+	if want := []string{"a", "ñ", "€", "😀", "\xe2\x82"}; !slices.Equal(chunks, want) {
+		t.Errorf("chunks %q, want %q", chunks, want)
+	}
+}
+
+func TestShellExecReturnsWhenChildKeepsOutputOpen(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	a := &App{}
+	start := time.Now()
+	result, err := a.shellExec(context.Background(), shellExecRequest{Cmd: "sh", Args: []string{"-c", "sleep 30 & echo done"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > shellWaitDelay+5*time.Second {
+		t.Errorf("shell.exec took %v", elapsed)
+	}
+	if result.Code != 0 || result.Stdout != "done\n" {
+		t.Errorf("shell.exec returned %+v", result)
+	}
+}
+
+func TestShellStream(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	a := &App{}
+	server := httptest.NewServer(http.HandlerFunc(a.shellStreamHandler))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(shellWaitDelay + 10*time.Second))
+
+	// Split "é" between two writes and leave a child holding stdout
+	script := `sleep 30 & printf '\303'; sleep 0.2; printf '\251'; exit 3`
+	if err := conn.WriteJSON(shellExecRequest{Cmd: "sh", Args: []string{"-c", script}}); err != nil {
+		t.Fatal(err)
+	}
+	var stdout strings.Builder
+	for {
+		var msg shellStreamMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("reading output: %v", err)
+		}
+		switch msg.Type {
+		case "stdout":
+			stdout.WriteString(msg.Data)
+		case "exit":
+			if msg.Code != 3 {
+				t.Errorf("exit code %d, want 3", msg.Code)
+			}
+			if stdout.String() != "é" {
+				t.Errorf("stdout %q, want %q", stdout.String(), "é")
+			}
+			return
+		default:
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	}
+}
+
+// startShellStream sends req to a new streaming shell connection.
+func startShellStream(t *testing.T, req shellExecRequest) *websocket.Conn {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	a := &App{}
+	server := httptest.NewServer(http.HandlerFunc(a.shellStreamHandler))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readShellExit returns the stdout of a streamed process and its exit code.
+func readShellExit(t *testing.T, conn *websocket.Conn) (string, int) {
+	t.Helper()
+	var stdout strings.Builder
+	for {
+		var msg shellStreamMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("reading output: %v", err)
+		}
+		switch msg.Type {
+		case "stdout":
+			stdout.WriteString(msg.Data)
+		case "exit":
+			return stdout.String(), msg.Code
+		case "error":
+			t.Fatalf("process failed: %s", msg.Message)
+		}
+	}
+}
+
+func TestShellStreamClosesStdinAfterRequestInput(t *testing.T) {
+	conn := startShellStream(t, shellExecRequest{Cmd: "sh", Args: []string{"-c", "cat"}, Stdin: "hello"})
+	if stdout, code := readShellExit(t, conn); stdout != "hello" || code != 0 {
+		t.Errorf("got %q with exit code %d, want %q with 0", stdout, code, "hello")
+	}
+}
+
+func TestShellStreamKillsProcessNotReadingStdin(t *testing.T) {
+	// More input than a pipe holds, for a process that never reads it
+	input := strings.Repeat("x", 1<<20)
+	conn := startShellStream(t, shellExecRequest{Cmd: "sh", Args: []string{"-c", "sleep 30"}, Stdin: input})
+	if err := conn.WriteJSON(shellControlMessage{Type: "kill"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, code := readShellExit(t, conn); code == 0 {
+		t.Errorf("exit code %d, want the process killed", code)
+	}
+}
@@ -0,0 +1,148 @@
+package gohta
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxSocketPathLength is the longest unix socket path accepted on all platforms. macOS
+// allows 104 bytes including the terminating NUL.
+const maxSocketPathLength = 103
+
+// ErrAlreadyRunning is returned by Run in single-instance mode when the launch was
+// forwarded to an instance of the app that is already running.
+var ErrAlreadyRunning = errors.New("another instance of the app is already running")
+
+// secondInstanceMessage is sent by a second launch to the running instance and
+// delivered to pages as the payload of the "second-instance" event.
+type secondInstanceMessage struct {
+	Args []string `json:"args"`
+	Argv []string `json:"argv"`
+	Cwd  string   `json:"cwd"`
+}
+
+// instanceSocketPath returns the unix socket the running instance listens on. It lives
+// in a directory only the current user can enter: $XDG_RUNTIME_DIR, or the gohta
+// directory in the user cache directory. IDs that would make the path too long for a
+// socket are replaced by their hash.
+func (a *App) instanceSocketPath() (string, error) {
+	dir, prefix := os.Getenv("XDG_RUNTIME_DIR"), "gohta-"
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir, prefix = filepath.Join(cacheDir, "gohta"), ""
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+	socketPath := filepath.Join(dir, prefix+a.opts.ID+".sock")
+	if len(socketPath) > maxSocketPathLength {
+		sum := sha256.Sum256([]byte(a.opts.ID))
+		socketPath = filepath.Join(dir, prefix+hex.EncodeToString(sum[:8])+".sock")
+	}
+	return socketPath, nil
+}
+
+// claimInstance makes this launch the running instance, listening for later launches
+// on socketPath, or forwards it to the instance that already is. It returns
+// ErrAlreadyRunning if the launch was forwarded.
+func (a *App) claimInstance(socketPath string) (net.Listener, error) {
+	if a.forwardToRunningInstance(socketPath) {
+		return nil, ErrAlreadyRunning
+	}
+	listener, err := a.listenForInstances(socketPath)
+	if err != nil {
+		// Another instance started listening since it was looked for
+		if a.forwardToRunningInstance(socketPath) {
+			return nil, ErrAlreadyRunning
+		}
+		return nil, fmt.Errorf("error listening for other instances: %w", err)
+	}
+	return listener, nil
+}
+
+// forwardToRunningInstance passes this launch to a running instance.
+// It returns true if an instance accepted it.
+func (a *App) forwardToRunningInstance(socketPath string) bool {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	cwd, _ := os.Getwd()
+	msg := secondInstanceMessage{Args: a.opts.Args, Argv: os.Args, Cwd: cwd}
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return false
+	}
+
+	// Wait for the acknowledgement so the launch is not lost if the instance is exiting
+	var ack bool
+	return json.NewDecoder(conn).Decode(&ack) == nil && ack
+}
+
+// listenForInstances accepts launches forwarded by later instances and emits them to pages.
+func (a *App) listenForInstances(socketPath string) (net.Listener, error) {
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		// The socket of a crashed instance is left behind. Remove it and retry, unless
+		// an instance still answers on it.
+		if conn, dialErr := net.DialTimeout("unix", socketPath, time.Second); dialErr == nil {
+			conn.Close()
+			return nil, err
+		}
+		os.Remove(socketPath)
+		if listener, err = net.Listen("unix", socketPath); err != nil {
+			return nil, err
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go a.handleSecondInstance(conn)
+		}
+	}()
+	return listener, nil
+}
+
+// handleSecondInstance reads a forwarded launch and notifies pages.
+func (a *App) handleSecondInstance(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var msg secondInstanceMessage
+	if err := json.NewDecoder(conn).Decode(&msg); err != nil {
+		if errors.Is(err, io.EOF) {
+			// A later instance only checked that this one is alive
+			return
+		}
+		slog.Error("❌ Error reading second instance message", "error", err)
+		return
+	}
+	slog.Info("📨 Second instance launched", "args", msg.Args)
+	a.Emit("second-instance", msg)
+	// The later instance waits for the acknowledgement for 5 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := a.focusWindow(ctx, mainWindowID); err != nil {
+		slog.Warn("⚠️  Could not focus window", "error", err)
+	}
+	json.NewEncoder(conn).Encode(true)
+}
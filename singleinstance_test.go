@@ -0,0 +1,141 @@
+package gohta
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenForInstancesReplacesStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	// Leave the socket file behind like a crashed instance
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &App{}
+	listener, err := a.listenForInstances(socketPath)
+	if err != nil {
+		t.Fatalf("listening over a stale socket: %v", err)
+	}
+	listener.Close()
+}
+
+func TestListenForInstancesKeepsLiveSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	a := &App{}
+	running, err := a.listenForInstances(socketPath)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	defer running.Close()
+
+	if listener, err := a.listenForInstances(socketPath); err == nil {
+		listener.Close()
+		t.Fatal("listening succeeded while an instance answers on the socket")
+	}
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("the running instance is no longer reachable: %v", err)
+	}
+	conn.Close()
+}
+
+func TestInstanceSocketPathUsesRuntimeDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	a := &App{opts: Options{ID: "notes"}}
+	socketPath, err := a.instanceSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(socketPath) != dir {
+		t.Errorf("socket path %s is not in %s", socketPath, dir)
+	}
+}
+
+func TestInstanceSocketPathHashesLongIDs(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	short, err := (&App{opts: Options{ID: "notes"}}).instanceSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(short) != "gohta-notes.sock" {
+		t.Errorf("short ID gives socket %s, want gohta-notes.sock", short)
+	}
+
+	paths := make(map[string]bool)
+	for _, id := range []string{strings.Repeat("a", 200), strings.Repeat("a", 199) + "b"} {
+		socketPath, err := (&App{opts: Options{ID: id}}).instanceSocketPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(socketPath) > maxSocketPathLength || filepath.Dir(socketPath) != dir {
+			t.Errorf("socket path %s is too long or not in %s", socketPath, dir)
+		}
+		paths[socketPath] = true
+	}
+	if len(paths) != 2 {
+		t.Errorf("different IDs share a socket path: %v", paths)
+	}
+}
+
+func TestClaimInstanceRetriesForward(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "app.sock")
+	running, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	defer running.Close()
+
+	// The running instance drops the first launch, like one that only just started
+	// listening, and accepts the next
+	forwarded := make(chan secondInstanceMessage, 1)
+	go func() {
+		dropped := false
+		for {
+			conn, err := running.Accept()
+			if err != nil {
+				return
+			}
+			var msg secondInstanceMessage
+			if err := json.NewDecoder(conn).Decode(&msg); err == nil {
+				if dropped {
+					json.NewEncoder(conn).Encode(true)
+					forwarded <- msg
+				}
+				dropped = true
+			}
+			conn.Close()
+		}
+	}()
+
+	a := &App{opts: Options{Args: []string{"notes.txt"}}}
+	if listener, err := a.claimInstance(socketPath); !errors.Is(err, ErrAlreadyRunning) {
+		if listener != nil {
+			listener.Close()
+		}
+		t.Fatalf("got %v, want the launch forwarded", err)
+	}
+	select {
+	case msg := <-forwarded:
+		if !slices.Equal(msg.Args, a.opts.Args) {
+			t.Errorf("forwarded args %v, want %v", msg.Args, a.opts.Args)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the running instance did not receive the launch")
+	}
+}
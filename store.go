@@ -0,0 +1,215 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// storeChange is the payload of the "store.change" event. Value is null when the key was removed.
+type storeChange struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Store is a persistent key-value store for app settings. Values are JSON and the store
+// is saved atomically to a JSON file after every change. It is safe for concurrent use.
+type Store struct {
+	path     string
+	data     map[string]json.RawMessage
+	mutex    sync.Mutex
+	onChange func(change storeChange)
+
+	// notifyMutex keeps change events in the order of the changes without holding
+	// mutex while they are sent.
+	notifyMutex sync.Mutex
+}
+
+// openStore loads the store saved at path. A missing file yields an empty store. A
+// file that is not valid JSON is moved aside so that the app still starts, with an
+// empty store.
+func openStore(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: make(map[string]json.RawMessage),
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &s.data); err != nil {
+		backupPath := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+		if renameErr := os.Rename(path, backupPath); renameErr != nil {
+			return nil, fmt.Errorf("invalid store file %s could not be moved aside: %w", path, renameErr)
+		}
+		slog.Warn("⚠️  Invalid store file moved aside, starting with an empty store", "path", path, "backup", backupPath, "error", err)
+		s.data = make(map[string]json.RawMessage)
+	}
+	return s, nil
+}
+
+// Get returns the JSON value stored under key.
+func (s *Store) Get(key string) (json.RawMessage, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+// Set stores value under key. value must be encodable as JSON.
+func (s *Store) Set(key string, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.data[key] = encoded
+	if err := s.save(); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	s.notifyAndUnlock([]storeChange{{Key: key, Value: encoded}})
+	return nil
+}
+
+// Delete removes key from the store.
+func (s *Store) Delete(key string) error {
+	s.mutex.Lock()
+	if _, ok := s.data[key]; !ok {
+		s.mutex.Unlock()
+		return nil
+	}
+	delete(s.data, key)
+	if err := s.save(); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	s.notifyAndUnlock([]storeChange{{Key: key}})
+	return nil
+}
+
+// Keys returns the stored keys in sorted order.
+func (s *Store) Keys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Clear removes all keys from the store.
+func (s *Store) Clear() error {
+	s.mutex.Lock()
+	removed := s.data
+	s.data = make(map[string]json.RawMessage)
+	if err := s.save(); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	changes := make([]storeChange, 0, len(removed))
+	for key := range removed {
+		changes = append(changes, storeChange{Key: key})
+	}
+	s.notifyAndUnlock(changes)
+	return nil
+}
+
+// notifyAndUnlock releases s.mutex, which the caller must hold, and then reports the
+// changes to the app, so that sending events never blocks other store calls.
+func (s *Store) notifyAndUnlock(changes []storeChange) {
+	s.notifyMutex.Lock()
+	defer s.notifyMutex.Unlock()
+	s.mutex.Unlock()
+	if s.onChange == nil {
+		return
+	}
+	for _, change := range changes {
+		s.onChange(change)
+	}
+}
+
+// save writes the store to a temporary file and renames it over the store file,
+// so a crash never leaves a partially written file. The caller must hold s.mutex.
+func (s *Store) save() error {
+	content, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, content)
+}
+
+// Store returns the persistent settings store of the app.
+func (a *App) Store() *Store {
+	return a.store
+}
+
+// initStore opens the store file under the user config directory for the app ID.
+func (a *App) initStore() error {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return fmt.Errorf("could not find user config directory: %w", err)
+	}
+	store, err := openStore(filepath.Join(configDir, a.opts.ID, "store.json"))
+	if err != nil {
+		return err
+	}
+	store.onChange = func(change storeChange) {
+		a.Emit("store.change", change)
+	}
+	a.store = store
+	return nil
+}
+
+type storeKeyRequest struct {
+	Key string `json:"key"`
+}
+
+type storeSetRequest struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// registerStoreAPI registers the store.* methods used by gohta.store in gohta.js.
+func (a *App) registerStoreAPI() {
+	Register(a, "store.get", func(ctx context.Context, req storeKeyRequest) (json.RawMessage, error) {
+		value, ok := a.store.Get(req.Key)
+		if !ok {
+			return json.RawMessage("null"), nil
+		}
+		return value, nil
+	})
+	Register(a, "store.set", func(ctx context.Context, req storeSetRequest) (bool, error) {
+		if req.Key == "" {
+			return false, &APIError{Status: http.StatusBadRequest, Message: "key is required"}
+		}
+		if len(req.Value) == 0 {
+			req.Value = json.RawMessage("null")
+		}
+		return true, a.store.Set(req.Key, req.Value)
+	})
+	Register(a, "store.delete", func(ctx context.Context, req storeKeyRequest) (bool, error) {
+		return true, a.store.Delete(req.Key)
+	})
+	Register(a, "store.keys", func(ctx context.Context, req struct{}) ([]string, error) {
+		return a.store.Keys(), nil
+	})
+	Register(a, "store.clear", func(ctx context.Context, req struct{}) (bool, error) {
+		return true, a.store.Clear()
+	})
+}
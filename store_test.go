@@ -0,0 +1,61 @@
+package gohta
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOpenStoreMovesCorruptFileAside(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte(`{"theme": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatalf("opening a corrupt store: %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Errorf("store has keys %v, want none", keys)
+	}
+	backups, _ := filepath.Glob(path + ".corrupt-*")
+	if len(backups) != 1 {
+		t.Fatalf("found backups %v, want one", backups)
+	}
+	if content, _ := os.ReadFile(backups[0]); string(content) != `{"theme": ` {
+		t.Errorf("backup contains %q", content)
+	}
+}
+
+func TestStoreNotifiesWithoutHoldingLock(t *testing.T) {
+	s, err := openStore(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []string
+	s.onChange = func(change storeChange) {
+		// Reading the store from a change handler would deadlock if the lock were held
+		value, _ := s.Get(change.Key)
+		seen = append(seen, change.Key+"="+string(value))
+	}
+
+	if err := s.Set("theme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("theme"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("size", 12); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{`theme="dark"`, "theme=", "size=12", "size="}
+	if !slices.Equal(seen, want) {
+		t.Errorf("changes %q, want %q", seen, want)
+	}
+}
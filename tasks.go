@@ -0,0 +1,210 @@
+package gohta
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Task states reported in taskInfo.Status.
+const (
+	taskRunning   = "running"
+	taskCompleted = "completed"
+	taskFailed    = "failed"
+	taskCancelled = "cancelled"
+)
+
+// taskRetention is how long a finished task stays in tasks.list.
+const taskRetention = 10 * time.Minute
+
+// Task is a long-running job started with StartTask. Its methods send events to the
+// pages and are safe for concurrent use.
+type Task struct {
+	id     string
+	app    *App
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	info  taskInfo
+}
+
+// taskInfo is the state of a task returned by tasks.list and tasks.get, and the
+// payload of the task.finished event.
+type taskInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Done       int64      `json:"done"`
+	Total      int64      `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type taskRequest struct {
+	ID string `json:"id"`
+}
+
+// StartTask runs fn in the background and returns the ID of the task at once, so that
+// an API method can hand it to the page. ctx is cancelled when the page calls
+// gohta.tasks.cancel(id) or the app quits. The task is reported as cancelled if fn
+// returns an error after ctx was cancelled.
+func (a *App) StartTask(name string, fn func(ctx context.Context, task *Task) error) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{app: a, cancel: cancel, done: make(chan struct{})}
+
+	a.tasksMutex.Lock()
+	a.nextTaskID++
+	task.id = "task-" + strconv.Itoa(a.nextTaskID)
+	task.info = taskInfo{ID: task.id, Name: name, Status: taskRunning, StartedAt: time.Now()}
+	a.tasks[task.id] = task
+	a.tasksMutex.Unlock()
+
+	slog.Info("⚙️  Task started", "task", task.id, "name", name)
+	go task.run(ctx, fn)
+	return task.id
+}
+
+// ID returns the ID of the task.
+func (t *Task) ID() string {
+	return t.id
+}
+
+// Progress reports that done of total units of work are finished. It sends the
+// task.progress event with { id, done, total }.
+func (t *Task) Progress(done, total int64) {
+	t.mutex.Lock()
+	t.info.Done, t.info.Total = done, total
+	t.mutex.Unlock()
+	t.app.Emit("task.progress", map[string]any{"id": t.id, "done": done, "total": total})
+}
+
+// Logf sends a line of output as the task.log event with { id, line }.
+func (t *Task) Logf(format string, args ...any) {
+	t.app.Emit("task.log", map[string]string{"id": t.id, "line": fmt.Sprintf(format, args...)})
+}
+
+// run calls fn and records how it ended.
+func (t *Task) run(ctx context.Context, fn func(ctx context.Context, task *Task) error) {
+	defer t.cancel()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		return fn(ctx, t)
+	}()
+
+	now := time.Now()
+	t.mutex.Lock()
+	switch {
+	case err == nil:
+		t.info.Status = taskCompleted
+	case ctx.Err() != nil:
+		t.info.Status = taskCancelled
+		t.info.Error = err.Error()
+	default:
+		t.info.Status = taskFailed
+		t.info.Error = err.Error()
+	}
+	t.info.FinishedAt = &now
+	info := t.info
+	t.mutex.Unlock()
+	close(t.done)
+
+	if info.Status == taskFailed {
+		slog.Error("❌ Task failed", "task", info.ID, "name", info.Name, "error", err)
+	} else {
+		slog.Info("⚙️  Task finished", "task", info.ID, "name", info.Name, "status", info.Status)
+	}
+	t.app.Emit("task.finished", info)
+
+	time.AfterFunc(taskRetention, func() {
+		t.app.tasksMutex.Lock()
+		delete(t.app.tasks, info.ID)
+		t.app.tasksMutex.Unlock()
+	})
+}
+
+// snapshot returns the current state of the task.
+func (t *Task) snapshot() taskInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.info
+}
+
+// task returns the task with the given ID, or a 404 error.
+func (a *App) task(id string) (*Task, error) {
+	a.tasksMutex.Lock()
+	defer a.tasksMutex.Unlock()
+	task, ok := a.tasks[id]
+	if !ok {
+		return nil, &APIError{Status: http.StatusNotFound, Message: fmt.Sprintf("no task with ID %q", id)}
+	}
+	return task, nil
+}
+
+// cancelTasks cancels the running tasks and waits up to timeout for them to return.
+func (a *App) cancelTasks(timeout time.Duration) {
+	a.tasksMutex.Lock()
+	tasks := make([]*Task, 0, len(a.tasks))
+	for _, task := range a.tasks {
+		tasks = append(tasks, task)
+	}
+	a.tasksMutex.Unlock()
+
+	deadline := time.After(timeout)
+	for _, task := range tasks {
+		task.cancel()
+	}
+	for _, task := range tasks {
+		select {
+		case <-task.done:
+		case <-deadline:
+			slog.Warn("⚠️  Task did not stop in time", "task", task.id)
+			return
+		}
+	}
+}
+
+// registerTasksAPI registers the tasks.* methods used by gohta.tasks in gohta.js.
+func (a *App) registerTasksAPI() {
+	Register(a, "tasks.list", func(ctx context.Context, req struct{}) ([]taskInfo, error) {
+		a.tasksMutex.Lock()
+		tasks := make([]taskInfo, 0, len(a.tasks))
+		for _, task := range a.tasks {
+			tasks = append(tasks, task.snapshot())
+		}
+		a.tasksMutex.Unlock()
+		sort.Slice(tasks, func(i, j int) bool {
+			return tasks[i].StartedAt.Before(tasks[j].StartedAt)
+		})
+		return tasks, nil
+	})
+	Register(a, "tasks.get", func(ctx context.Context, req taskRequest) (taskInfo, error) {
+		task, err := a.task(req.ID)
+		if err != nil {
+			return taskInfo{}, err
+		}
+		return task.snapshot(), nil
+	})
+	Register(a, "tasks.cancel", func(ctx context.Context, req taskRequest) (bool, error) {
+		task, err := a.task(req.ID)
+		if err != nil {
+			return false, err
+		}
+		if task.snapshot().Status != taskRunning {
+			return false, nil
+		}
+		task.cancel()
+		return true, nil
+	})
+}
@@ -0,0 +1,126 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestTaskApp returns an app whose event hub has a single page, and the events
+// and responses that page receives.
+func newTestTaskApp(t *testing.T) (*App, *websocket.Conn, <-chan rpcRequest) {
+	t.Helper()
+	a := &App{apiMethods: make(map[string]APIFunc), events: newEventHub(), tasks: make(map[string]*Task)}
+	a.registerTasksAPI()
+	conn := dialTestRPC(t, a)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.events.connected("")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("page did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	messages := make(chan rpcRequest, 10)
+	go func() {
+		for {
+			var message rpcRequest
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+	return a, conn, messages
+}
+
+// waitForEvent decodes the params of the next event received by the page.
+func waitForEvent(t *testing.T, messages <-chan rpcRequest, event string, params any) {
+	t.Helper()
+	for {
+		select {
+		case message := <-messages:
+			if message.Method == event {
+				if err := json.Unmarshal(message.Params, params); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("page did not receive %s", event)
+		}
+	}
+}
+
+func TestTaskSendsProgressAndFinished(t *testing.T) {
+	a, _, messages := newTestTaskApp(t)
+	id := a.StartTask("count", func(ctx context.Context, task *Task) error {
+		task.Progress(1, 2)
+		return nil
+	})
+
+	var progress struct {
+		ID    string `json:"id"`
+		Done  int64  `json:"done"`
+		Total int64  `json:"total"`
+	}
+	waitForEvent(t, messages, "task.progress", &progress)
+	if progress.ID != id || progress.Done != 1 || progress.Total != 2 {
+		t.Errorf("got progress %+v, want 1 of 2 for %s", progress, id)
+	}
+	var finished taskInfo
+	waitForEvent(t, messages, "task.finished", &finished)
+	if finished.ID != id || finished.Status != taskCompleted || finished.Done != 1 || finished.FinishedAt == nil {
+		t.Errorf("got finished %+v, want %s completed", finished, id)
+	}
+}
+
+func TestTaskCancelledByPage(t *testing.T) {
+	a, conn, messages := newTestTaskApp(t)
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	id := a.StartTask("wait", func(ctx context.Context, task *Task) error {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return ctx.Err()
+	})
+	<-started
+
+	if err := conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tasks.cancel", "params": taskRequest{ID: id}}); err != nil {
+		t.Fatal(err)
+	}
+	var finished taskInfo
+	waitForEvent(t, messages, "task.finished", &finished)
+	if err := <-stopped; !errors.Is(err, context.Canceled) {
+		t.Errorf("task context ended with %v, want %v", err, context.Canceled)
+	}
+	if finished.ID != id || finished.Status != taskCancelled {
+		t.Errorf("got finished %+v, want %s cancelled", finished, id)
+	}
+}
+
+func TestTaskRecoversPanic(t *testing.T) {
+	a, _, messages := newTestTaskApp(t)
+	id := a.StartTask("crash", func(ctx context.Context, task *Task) error {
+		panic("boom")
+	})
+
+	var finished taskInfo
+	waitForEvent(t, messages, "task.finished", &finished)
+	if finished.ID != id || finished.Status != taskFailed || !strings.Contains(finished.Error, "boom") {
+		t.Errorf("got finished %+v, want %s failed with the panic", finished, id)
+	}
+	task, err := a.task(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := task.snapshot(); info.Status != taskFailed {
+		t.Errorf("tasks.get reports %s, want %s", info.Status, taskFailed)
+	}
+}
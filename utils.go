@@ -1,6 +1,53 @@
-package main
+package gohta
 
-import "strings"
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sanitizeID makes an app ID safe to use as a directory name.
+func sanitizeID(id string) string {
+	id = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, id)
+	id = strings.Trim(id, ".-")
+	if id == "" {
+		return "gohta"
+	}
+	return id
+}
+
+// writeFileAtomic replaces the file at path with content, so a crash never leaves a
+// partially written file behind.
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(content); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
 
 func ternary[T any](condition bool, trueValue, falseValue T) T {
 	if condition {
@@ -10,6 +57,15 @@ func ternary[T any](condition bool, trueValue, falseValue T) T {
 }
 
 // convertFileSrc removes file:// prefix and adds /file/ prefix to create a new source URL.
-func convertFileSrc(filePath string) string {
-	return "/file/" + strings.TrimPrefix(filePath, "file://")
+// It fails with errPathNotAllowed if the file is outside the allowed roots.
+func (a *App) convertFileSrc(filePath string) (string, error) {
+	trimmed := strings.TrimPrefix(filePath, "file://")
+	decoded, err := url.PathUnescape(trimmed)
+	if err != nil {
+		decoded = trimmed
+	}
+	if _, err := a.checkAllowedPath(localPathFromURL(decoded)); err != nil {
+		return "", err
+	}
+	return "/file/" + trimmed, nil
 }
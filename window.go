@@ -0,0 +1,367 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// mainWindowID identifies the window opened at launch.
+	mainWindowID = "main"
+	// windowQueryParam carries the window ID in the URL of windows opened by window.open.
+	windowQueryParam = "gohta_window"
+	// windowTitleParam carries the title requested with window.open.
+	windowTitleParam = "gohta_title"
+)
+
+// windowBounds mirrors Browser.Bounds of the DevTools protocol. WindowState is
+// "normal", "minimized", "maximized" or "fullscreen".
+type windowBounds struct {
+	Left        *int   `json:"left,omitempty"`
+	Top         *int   `json:"top,omitempty"`
+	Width       *int   `json:"width,omitempty"`
+	Height      *int   `json:"height,omitempty"`
+	WindowState string `json:"windowState,omitempty"`
+}
+
+type cdpTargetInfo struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+}
+
+// windowRequest selects the window of a window.* call. An empty ID means the main window.
+type windowRequest struct {
+	Window string `json:"window"`
+}
+
+// windowOpenRequest is the request of window.open. Path is relative to the app root.
+type windowOpenRequest struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Title  string `json:"title"`
+}
+
+// windowInfo describes an open window in window.list.
+type windowInfo struct {
+	ID    string `json:"id"`
+	Path  string `json:"path"`
+	Title string `json:"title"`
+}
+
+// connectDevTools waits for the browser to answer on client and reports whether it
+// did. Window control is unavailable if not.
+func (a *App) connectDevTools(ctx context.Context, client *cdpClient) bool {
+	if client == nil {
+		slog.Warn("⚠️  Window control unavailable: the browser was started without DevTools")
+		return false
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	if err := client.Call(waitCtx, "Browser.getVersion", nil, nil); err != nil {
+		slog.Warn("⚠️  Window control unavailable: could not connect to DevTools", "error", err)
+		client.Close()
+		return false
+	}
+
+	a.cdpMutex.Lock()
+	a.cdp = client
+	a.cdpMutex.Unlock()
+	slog.Info("🪟 Connected to Chrome DevTools for window control")
+	return true
+}
+
+// devTools returns the DevTools client, or an error if Chrome is not connected.
+func (a *App) devTools() (*cdpClient, error) {
+	a.cdpMutex.Lock()
+	defer a.cdpMutex.Unlock()
+	if a.cdp == nil {
+		return nil, &APIError{Status: http.StatusServiceUnavailable, Message: "window control is not available"}
+	}
+	return a.cdp, nil
+}
+
+// windowIDFromURL returns the window ID in the URL a window was opened with.
+func windowIDFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if id := u.Query().Get(windowQueryParam); id != "" {
+		return id
+	}
+	return mainWindowID
+}
+
+// windowTarget finds the DevTools target of a window. A window is recognized by the URL
+// it was opened with, and its target is remembered so it is still found after the page
+// navigates.
+func (a *App) windowTarget(ctx context.Context, cdp *cdpClient, windowID string) (string, error) {
+	if windowID == "" {
+		windowID = mainWindowID
+	}
+	var result struct {
+		TargetInfos []cdpTargetInfo `json:"targetInfos"`
+	}
+	if err := cdp.Call(ctx, "Target.getTargets", nil, &result); err != nil {
+		return "", err
+	}
+
+	a.windowsMutex.Lock()
+	defer a.windowsMutex.Unlock()
+	var pages []cdpTargetInfo
+	for _, target := range result.TargetInfos {
+		if target.Type == "page" && strings.HasPrefix(target.URL, a.baseURL+"/") {
+			pages = append(pages, target)
+		}
+	}
+
+	known, ok := a.windowTargets[windowID]
+	assigned := make(map[string]bool)
+	for _, targetID := range a.windowTargets {
+		assigned[targetID] = true
+	}
+	for _, target := range pages {
+		if ok && target.TargetID == known {
+			return known, nil
+		}
+	}
+	for _, target := range pages {
+		if !assigned[target.TargetID] && windowIDFromURL(target.URL) == windowID {
+			a.windowTargets[windowID] = target.TargetID
+			return target.TargetID, nil
+		}
+	}
+	delete(a.windowTargets, windowID)
+	return "", &APIError{Status: http.StatusNotFound, Message: "app window not found: " + windowID}
+}
+
+// browserWindowID returns the browser window ID of an app window.
+func (a *App) browserWindowID(ctx context.Context, cdp *cdpClient, windowID string) (int, error) {
+	targetID, err := a.windowTarget(ctx, cdp, windowID)
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		WindowID int `json:"windowId"`
+	}
+	err = cdp.Call(ctx, "Browser.getWindowForTarget", map[string]string{"targetId": targetID}, &result)
+	return result.WindowID, err
+}
+
+// getWindowBounds returns the position, size and state of an app window.
+func (a *App) getWindowBounds(ctx context.Context, windowID string) (windowBounds, error) {
+	cdp, err := a.devTools()
+	if err != nil {
+		return windowBounds{}, err
+	}
+	browserWindowID, err := a.browserWindowID(ctx, cdp, windowID)
+	if err != nil {
+		return windowBounds{}, err
+	}
+	var result struct {
+		Bounds windowBounds `json:"bounds"`
+	}
+	err = cdp.Call(ctx, "Browser.getWindowBounds", map[string]int{"windowId": browserWindowID}, &result)
+	return result.Bounds, err
+}
+
+// setWindowBounds changes an app window. Chrome only accepts a position or size
+// for normal windows, so the window is restored first in that case.
+func (a *App) setWindowBounds(ctx context.Context, windowID string, bounds windowBounds) error {
+	cdp, err := a.devTools()
+	if err != nil {
+		return err
+	}
+	browserWindowID, err := a.browserWindowID(ctx, cdp, windowID)
+	if err != nil {
+		return err
+	}
+
+	if bounds.WindowState == "" {
+		restore := map[string]any{"windowId": browserWindowID, "bounds": windowBounds{WindowState: "normal"}}
+		if err := cdp.Call(ctx, "Browser.setWindowBounds", restore, nil); err != nil {
+			return err
+		}
+	}
+	return cdp.Call(ctx, "Browser.setWindowBounds", map[string]any{"windowId": browserWindowID, "bounds": bounds}, nil)
+}
+
+// focusWindow brings an app window to the front.
+func (a *App) focusWindow(ctx context.Context, windowID string) error {
+	cdp, err := a.devTools()
+	if err != nil {
+		return err
+	}
+	targetID, err := a.windowTarget(ctx, cdp, windowID)
+	if err != nil {
+		return err
+	}
+	return cdp.Call(ctx, "Target.activateTarget", map[string]string{"targetId": targetID}, nil)
+}
+
+// closeWindow closes an app window.
+func (a *App) closeWindow(ctx context.Context, windowID string) error {
+	cdp, err := a.devTools()
+	if err != nil {
+		return err
+	}
+	targetID, err := a.windowTarget(ctx, cdp, windowID)
+	if err != nil {
+		return err
+	}
+	return cdp.Call(ctx, "Target.closeTarget", map[string]string{"targetId": targetID}, nil)
+}
+
+// openWindow opens another app-mode window on the same server and returns its ID.
+// The browser is launched again with the profile of the main window, so it hands the
+// window to the running browser, which shares the token cookie.
+func (a *App) openWindow(ctx context.Context, req windowOpenRequest) (string, error) {
+	if a.launcher == nil {
+		return "", &APIError{Status: http.StatusServiceUnavailable, Message: "no browser available to open windows"}
+	}
+	if (req.Width > 0) != (req.Height > 0) || req.Width < 0 || req.Height < 0 {
+		return "", &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid size %dx%d", req.Width, req.Height)}
+	}
+	if req.Path == "" {
+		req.Path = a.entryPath()
+	}
+	target, err := url.Parse(req.Path)
+	if err != nil || target.Scheme != "" || target.Host != "" {
+		return "", &APIError{Status: http.StatusBadRequest, Message: "path must be relative to the app root: " + req.Path}
+	}
+
+	a.windowsMutex.Lock()
+	a.nextWindowID++
+	windowID := fmt.Sprintf("window-%d", a.nextWindowID)
+	a.windowsMutex.Unlock()
+
+	query := target.Query()
+	query.Set(windowQueryParam, windowID)
+	if req.Title != "" {
+		query.Set(windowTitleParam, req.Title)
+	}
+	target.RawQuery = query.Encode()
+	target.Path = "/app/" + strings.TrimPrefix(target.Path, "/")
+
+	var args []string
+	if req.Width > 0 {
+		args = append(args, fmt.Sprintf("--window-size=%d,%d", req.Width, req.Height))
+	}
+	// The running browser takes over this launch, so it needs no DevTools pipe
+	cmd, err := openChromeAppMode(a.launcher, a.baseURL+target.String(), a.profileDir, args, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not open window: %w", err)
+	}
+	go cmd.Wait()
+	slog.Info("🪟 Opened window", "window", windowID, "path", req.Path)
+
+	if req.Width > 0 {
+		// Chrome ignores the window size of a launch handed to a running browser
+		go a.sizeNewWindow(windowID, req.Width, req.Height)
+	}
+	return windowID, nil
+}
+
+// sizeNewWindow waits for a window opened by openWindow to appear and resizes it.
+func (a *App) sizeNewWindow(windowID string, width, height int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		err := a.setWindowBounds(ctx, windowID, windowBounds{Width: &width, Height: &height})
+		if err == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			slog.Warn("⚠️  Could not size window", "window", windowID, "error", err)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// registerWindowAPI registers the window.* methods used by gohta.window in gohta.js.
+func (a *App) registerWindowAPI() {
+	setState := func(state string) func(context.Context, windowRequest) (bool, error) {
+		return func(ctx context.Context, req windowRequest) (bool, error) {
+			err := a.setWindowBounds(ctx, req.Window, windowBounds{WindowState: state})
+			return err == nil, err
+		}
+	}
+
+	Register(a, "window.getBounds", func(ctx context.Context, req windowRequest) (windowBounds, error) {
+		return a.getWindowBounds(ctx, req.Window)
+	})
+	Register(a, "window.resize", func(ctx context.Context, req struct {
+		windowRequest
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}) (bool, error) {
+		if req.Width <= 0 || req.Height <= 0 {
+			return false, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid size %dx%d", req.Width, req.Height)}
+		}
+		err := a.setWindowBounds(ctx, req.Window, windowBounds{Width: &req.Width, Height: &req.Height})
+		return err == nil, err
+	})
+	Register(a, "window.move", func(ctx context.Context, req struct {
+		windowRequest
+		X int `json:"x"`
+		Y int `json:"y"`
+	}) (bool, error) {
+		err := a.setWindowBounds(ctx, req.Window, windowBounds{Left: &req.X, Top: &req.Y})
+		return err == nil, err
+	})
+	Register(a, "window.minimize", setState("minimized"))
+	Register(a, "window.maximize", setState("maximized"))
+	Register(a, "window.fullscreen", setState("fullscreen"))
+	Register(a, "window.restore", setState("normal"))
+	Register(a, "window.focus", func(ctx context.Context, req windowRequest) (bool, error) {
+		err := a.focusWindow(ctx, req.Window)
+		return err == nil, err
+	})
+	Register(a, "window.close", func(ctx context.Context, req windowRequest) (bool, error) {
+		err := a.closeWindow(ctx, req.Window)
+		return err == nil, err
+	})
+	Register(a, "window.setTitle", func(ctx context.Context, req struct {
+		windowRequest
+		Title string `json:"title"`
+	}) (bool, error) {
+		// The page in the window sets its own title
+		if !a.pages.hasWindow(req.Window) {
+			return false, &APIError{Status: http.StatusNotFound, Message: "window is not open: " + req.Window}
+		}
+		a.EmitTo(req.Window, "window.setTitle", map[string]string{"title": req.Title})
+		return true, nil
+	})
+	Register(a, "window.open", a.openWindow)
+	Register(a, "window.list", func(ctx context.Context, req struct{}) ([]windowInfo, error) {
+		return a.pages.windows(), nil
+	})
+	Register(a, "window.postMessage", func(ctx context.Context, req struct {
+		From   string          `json:"from"`
+		Target string          `json:"target"`
+		Data   json.RawMessage `json:"data"`
+	}) (bool, error) {
+		message := map[string]any{"from": req.From, "data": req.Data}
+		if req.Target == "*" {
+			a.Emit("window.message", message)
+			return true, nil
+		}
+		if !a.pages.hasWindow(req.Target) {
+			return false, &APIError{Status: http.StatusNotFound, Message: "window is not open: " + req.Target}
+		}
+		a.EmitTo(req.Target, "window.message", message)
+		return true, nil
+	})
+}
@@ -0,0 +1,55 @@
+package gohta
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWindowSetTitleReachesTargetWindow(t *testing.T) {
+	a := &App{apiMethods: make(map[string]APIFunc), events: newEventHub(), pages: newPageTracker()}
+	a.registerWindowAPI()
+	a.pages.heartbeat("settings-page", windowInfo{ID: "settings"})
+	server := httptest.NewServer(http.HandlerFunc(a.rpcHandler))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?window=settings", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.events.connected("settings")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("page did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := callAPI(t, a, "window.setTitle", map[string]string{"window": "settings", "title": "Preferences"}); err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		Method string `json:"method"`
+		Params struct {
+			Title string `json:"title"`
+		} `json:"params"`
+	}
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Method != "window.setTitle" || event.Params.Title != "Preferences" {
+		t.Errorf("page received %+v, want the new title", event)
+	}
+
+	_, err = callAPI(t, a, "window.setTitle", map[string]string{"window": "closed", "title": "Gone"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("setting the title of a closed window: got %v, want a 404 error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package gohta
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedWindowState is the geometry of the main window, saved in window.json when the
+// app exits and restored on the next launch. Left, Top, Width and Height are the
+// bounds of the window when it is not maximized.
+type savedWindowState struct {
+	Left      int  `json:"left"`
+	Top       int  `json:"top"`
+	Width     int  `json:"width"`
+	Height    int  `json:"height"`
+	Maximized bool `json:"maximized"`
+}
+
+// valid reports whether the saved size is usable.
+func (s savedWindowState) valid() bool {
+	return s.Width > 0 && s.Height > 0 && s.Width <= maxWindowDimension && s.Height <= maxWindowDimension
+}
+
+// windowStatePath returns the path of window.json in the config directory of the app.
+func (a *App) windowStatePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, a.opts.ID, "window.json"), nil
+}
+
+// loadWindowState reads the window geometry saved by the last session.
+func (a *App) loadWindowState() (savedWindowState, bool) {
+	var state savedWindowState
+	statePath, err := a.windowStatePath()
+	if err != nil {
+		return state, false
+	}
+	content, err := os.ReadFile(statePath)
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(content, &state); err != nil || !state.valid() {
+		slog.Warn("⚠️  Ignoring invalid saved window state", "path", statePath)
+		return state, false
+	}
+	return state, true
+}
+
+// saveWindowState writes the last known geometry of the main window.
+func (a *App) saveWindowState() {
+	a.windowStateMutex.Lock()
+	state, ok := a.windowState, a.hasWindowState
+	a.windowStateMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	statePath, err := a.windowStatePath()
+	if err != nil {
+		slog.Warn("⚠️  Could not save window state", "error", err)
+		return
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(statePath, content)
+	}
+	if err != nil {
+		slog.Warn("⚠️  Could not save window state", "error", err)
+	}
+}
+
+// trackWindowState records the geometry of the main window every few seconds until
+// ctx is done. The window is gone by the time the app exits, so the last recorded
+// geometry is what gets saved.
+func (a *App) trackWindowState(ctx context.Context) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, time.Second)
+		bounds, err := a.getWindowBounds(callCtx, mainWindowID)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		a.windowStateMutex.Lock()
+		switch bounds.WindowState {
+		case "normal":
+			if bounds.Left != nil && bounds.Top != nil && bounds.Width != nil && bounds.Height != nil {
+				a.windowState = savedWindowState{Left: *bounds.Left, Top: *bounds.Top, Width: *bounds.Width, Height: *bounds.Height}
+				a.hasWindowState = a.windowState.valid()
+			}
+		case "maximized":
+			// Keep the normal bounds so that restoring the window returns to them. A window
+			// that started maximized has none, so its maximized bounds are used.
+			if !a.hasWindowState && bounds.Left != nil && bounds.Top != nil && bounds.Width != nil && bounds.Height != nil {
+				a.windowState = savedWindowState{Left: *bounds.Left, Top: *bounds.Top, Width: *bounds.Width, Height: *bounds.Height}
+				a.hasWindowState = a.windowState.valid()
+			}
+			a.windowState.Maximized = true
+		}
+		// Minimized and fullscreen windows keep the previous state
+		a.windowStateMutex.Unlock()
+	}
+}
+
+// applyWindowState overrides the window size, position and state with saved ones.
+func (o *ApplicationOptions) applyWindowState(state savedWindowState) {
+	o.Width, o.Height = state.Width, state.Height
+	o.X, o.Y, o.HasPosition = state.Left, state.Top, true
+	o.WindowState = ternary(state.Maximized, "maximized", "normal")
+}